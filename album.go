@@ -0,0 +1,272 @@
+package tgbotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMediaGroupWait is how long a MediaGroupAggregator waits for more
+// messages of a media group by default.
+const DefaultMediaGroupWait = time.Second
+
+// Limits on the number of items in a media group
+const (
+	MinMediaGroupSize = 2
+	MaxMediaGroupSize = 10
+)
+
+// Album is a media group, sent as separate messages sharing a MediaGroupID.
+type Album struct {
+	MediaGroupID string
+	// Updates are the updates containing the messages, in the same order as
+	// Messages.
+	Updates []Update
+	// Messages are the messages of the media group, ordered by MessageID.
+	Messages []*Message
+	// Caption is the message with the caption of the album, or nil if no
+	// message has a caption.
+	//
+	// optional
+	Caption *Message
+}
+
+// AlbumHandlerFunc handles a complete media group.
+type AlbumHandlerFunc func(ctx context.Context, bot *BotAPI, album Album) (Chattable, error)
+
+type pendingAlbum struct {
+	bot     *BotAPI
+	updates []Update
+	timer   *time.Timer
+}
+
+// MediaGroupAggregator collects messages belonging to a media group and
+// calls an AlbumHandlerFunc once no more messages of the group have arrived
+// for Wait.
+//
+// Telegram gives no signal for when a media group is complete, so the album
+// handler is called after a quiet period.
+type MediaGroupAggregator struct {
+	Handler AlbumHandlerFunc
+	// Wait is the quiet period after the last message of a media group.
+	// Defaults to DefaultMediaGroupWait.
+	Wait time.Duration
+
+	mu     sync.Mutex
+	groups map[string]*pendingAlbum
+}
+
+// NewMediaGroupAggregator creates a new MediaGroupAggregator calling handler
+// for every complete media group.
+func NewMediaGroupAggregator(handler AlbumHandlerFunc) *MediaGroupAggregator {
+	return &MediaGroupAggregator{
+		Handler: handler,
+		Wait:    DefaultMediaGroupWait,
+		groups:  make(map[string]*pendingAlbum),
+	}
+}
+
+// albumMessage returns the new message or channel post of an update if it
+// belongs to a media group.
+func albumMessage(update Update) *Message {
+	message := update.Message
+	if message == nil {
+		message = update.ChannelPost
+	}
+
+	if message == nil || message.MediaGroupID == "" {
+		return nil
+	}
+
+	return message
+}
+
+// Add buffers an update if it contains a message belonging to a media group
+// and reports whether it did.
+func (a *MediaGroupAggregator) Add(bot *BotAPI, update Update) bool {
+	message := albumMessage(update)
+	if message == nil {
+		return false
+	}
+
+	wait := a.Wait
+	if wait <= 0 {
+		wait = DefaultMediaGroupWait
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.groups == nil {
+		a.groups = make(map[string]*pendingAlbum)
+	}
+
+	id := message.MediaGroupID
+
+	group, ok := a.groups[id]
+	if !ok {
+		group = &pendingAlbum{bot: bot}
+		group.timer = time.AfterFunc(wait, func() {
+			a.flush(id)
+		})
+		a.groups[id] = group
+	} else {
+		group.timer.Reset(wait)
+	}
+
+	group.updates = append(group.updates, update)
+
+	return true
+}
+
+// Flush calls the album handler for every media group still being
+// collected, without waiting for the quiet period.
+func (a *MediaGroupAggregator) Flush() {
+	a.mu.Lock()
+	ids := make([]string, 0, len(a.groups))
+	for id := range a.groups {
+		ids = append(ids, id)
+	}
+	a.mu.Unlock()
+
+	for _, id := range ids {
+		a.flush(id)
+	}
+}
+
+// flush removes a media group and calls the album handler with it.
+func (a *MediaGroupAggregator) flush(id string) {
+	a.mu.Lock()
+	group, ok := a.groups[id]
+	if ok {
+		group.timer.Stop()
+		delete(a.groups, id)
+	}
+	a.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	album := newAlbum(id, group.updates)
+	last := album.Updates[len(album.Updates)-1]
+
+	// The handler runs on a timer goroutine without the middlewares of a
+	// Dispatcher, so panics are recovered here.
+	subject := "media group " + id
+	c, err := callRecovered(subject, func() (Chattable, error) {
+		return a.Handler(context.Background(), group.bot, album)
+	})
+	if err != nil {
+		logHandlerError(subject, err)
+		return
+	}
+
+	sendHandlerResult(group.bot, last, c)
+}
+
+func newAlbum(id string, updates []Update) Album {
+	sort.SliceStable(updates, func(i, j int) bool {
+		return albumMessage(updates[i]).MessageID < albumMessage(updates[j]).MessageID
+	})
+
+	album := Album{
+		MediaGroupID: id,
+		Updates:      updates,
+		Messages:     make([]*Message, len(updates)),
+	}
+
+	for i, update := range updates {
+		message := albumMessage(update)
+		album.Messages[i] = message
+
+		if album.Caption == nil && message.Caption != "" {
+			album.Caption = message
+		}
+	}
+
+	return album
+}
+
+// Middleware returns a Middleware which buffers messages belonging to media
+// groups and passes every other update to the next handler.
+//
+// The album handler is called later, outside of the middleware chain, so
+// middlewares such as AllowUsersMiddleware must come before this one in order to apply
+// to media groups.
+func (a *MediaGroupAggregator) Middleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+			if a.Add(bot, update) {
+				return nil, nil
+			}
+
+			return next(ctx, bot, update)
+		}
+	}
+}
+
+// ValidateMediaGroup checks that media can be sent in media groups.
+//
+// There must be at least MinMediaGroupSize items, and photos and videos can
+// not be mixed with audio or documents, and audio not with documents.
+// Animations can't be sent in media groups.
+func ValidateMediaGroup(media []interface{}) error {
+	if len(media) < MinMediaGroupSize {
+		return fmt.Errorf("media group must contain at least %d items", MinMediaGroupSize)
+	}
+
+	var kind string
+
+	for i, item := range media {
+		var itemKind string
+
+		switch item.(type) {
+		case InputMediaPhoto, InputMediaVideo:
+			itemKind = "photos and videos"
+		case InputMediaAudio:
+			itemKind = "audio"
+		case InputMediaDocument:
+			itemKind = "documents"
+		case InputMediaAnimation:
+			return errors.New("animations can't be sent in media groups")
+		default:
+			return fmt.Errorf("unsupported media group item %d of type %T", i, item)
+		}
+
+		if kind == "" {
+			kind = itemKind
+		} else if kind != itemKind {
+			return fmt.Errorf("media group can't mix %s with %s", kind, itemKind)
+		}
+	}
+
+	return nil
+}
+
+// splitMediaGroup splits media into as few media groups as possible with
+// sizes as even as possible, so no group is smaller than MinMediaGroupSize.
+func splitMediaGroup(media []interface{}) [][]interface{} {
+	count := (len(media) + MaxMediaGroupSize - 1) / MaxMediaGroupSize
+	if count == 0 {
+		return nil
+	}
+
+	size, extra := len(media)/count, len(media)%count
+
+	groups := make([][]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		n := size
+		if i < extra {
+			n++
+		}
+
+		groups = append(groups, media[:n])
+		media = media[n:]
+	}
+
+	return groups
+}
@@ -0,0 +1,181 @@
+package tgbotapi
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func newTestAlbumUpdate(updateID, messageID int, mediaGroupID, caption string) Update {
+	return Update{UpdateID: updateID, Message: &Message{
+		MessageID:    messageID,
+		Chat:         &Chat{ID: 3, Type: "private"},
+		MediaGroupID: mediaGroupID,
+		Caption:      caption,
+		Photo:        []PhotoSize{{FileID: "photo"}},
+	}}
+}
+
+func TestMediaGroupAggregator(t *testing.T) {
+	albums := make(chan Album, 2)
+
+	aggregator := NewMediaGroupAggregator(func(ctx context.Context, bot *BotAPI, album Album) (Chattable, error) {
+		albums <- album
+		return NewMessage(3, "got album"), nil
+	})
+	aggregator.Wait = 20 * time.Millisecond
+
+	var called []string
+
+	d := NewDispatcher()
+	d.Use(aggregator.Middleware())
+	d.Fallback(namedHandler("fallback", &called))
+
+	client := &testClient{}
+	bot := newTestBot(client)
+	ctx := context.Background()
+
+	d.Dispatch(ctx, bot, newTestAlbumUpdate(1, 11, "a", ""))
+	d.Dispatch(ctx, bot, newTestAlbumUpdate(3, 20, "b", ""))
+	d.Dispatch(ctx, bot, newTestCommandUpdate("private", "hi"))
+	d.Dispatch(ctx, bot, newTestAlbumUpdate(2, 10, "a", "caption"))
+
+	if len(called) != 1 {
+		t.Errorf("expected only the plain message to pass through, got %v", called)
+	}
+
+	received := make(map[string]Album)
+	for i := 0; i < 2; i++ {
+		select {
+		case album := <-albums:
+			received[album.MediaGroupID] = album
+		case <-time.After(time.Second):
+			t.Fatal("expected albums to be emitted after the quiet period")
+		}
+	}
+
+	album := received["a"]
+	if len(album.Messages) != 2 || album.Messages[0].MessageID != 10 || album.Messages[1].MessageID != 11 {
+		t.Errorf("expected messages in order, got %+v", album.Messages)
+	}
+
+	if album.Caption == nil || album.Caption.MessageID != 10 {
+		t.Errorf("expected caption message to be identified, got %+v", album.Caption)
+	}
+
+	if album := received["b"]; len(album.Messages) != 1 || album.Caption != nil {
+		t.Errorf("unexpected album %+v", album)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(client.Requests()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if requests := client.Requests(); len(requests) != 2 {
+		t.Errorf("expected album handler results to be sent, got %v", requests)
+	}
+}
+
+func TestMediaGroupAggregatorFlush(t *testing.T) {
+	var albums []Album
+
+	aggregator := NewMediaGroupAggregator(func(ctx context.Context, bot *BotAPI, album Album) (Chattable, error) {
+		albums = append(albums, album)
+		return nil, nil
+	})
+	aggregator.Wait = time.Hour
+
+	bot := newTestBot(&testClient{})
+	aggregator.Add(bot, newTestAlbumUpdate(1, 10, "a", ""))
+	aggregator.Flush()
+
+	if len(albums) != 1 {
+		t.Errorf("expected pending album to be flushed, got %v", albums)
+	}
+}
+
+func TestMediaGroupAggregatorPanic(t *testing.T) {
+	aggregator := NewMediaGroupAggregator(func(ctx context.Context, bot *BotAPI, album Album) (Chattable, error) {
+		panic("boom")
+	})
+	aggregator.Wait = time.Hour
+
+	client := &testClient{}
+	aggregator.Add(newTestBot(client), newTestAlbumUpdate(1, 10, "a", ""))
+	aggregator.Flush()
+
+	if len(client.Requests()) != 0 {
+		t.Errorf("expected nothing to be sent, got %v", client.Requests())
+	}
+}
+
+func TestValidateMediaGroup(t *testing.T) {
+	photo := NewInputMediaPhoto(FileID("photo"))
+	video := NewInputMediaVideo(FileID("video"))
+	document := NewInputMediaDocument(FileID("document"))
+
+	if err := ValidateMediaGroup([]interface{}{photo, video}); err != nil {
+		t.Errorf("expected photos and videos to mix, got %v", err)
+	}
+
+	for _, media := range [][]interface{}{
+		{photo},
+		{photo, document},
+		{NewInputMediaAudio(FileID("audio")), document},
+		{photo, NewInputMediaAnimation(FileID("animation"))},
+		{photo, "photo"},
+	} {
+		if err := ValidateMediaGroup(media); err == nil {
+			t.Errorf("expected %v to be invalid", media)
+		}
+	}
+}
+
+func TestSendAlbum(t *testing.T) {
+	client := &testClient{results: map[string]string{
+		"sendMediaGroup": `[{"message_id":1,"date":0,"chat":{"id":3,"type":"private"}}]`,
+	}}
+
+	media := make([]interface{}, 21)
+	for i := range media {
+		media[i] = NewInputMediaPhoto(FileID("photo"))
+	}
+
+	first := media[0].(InputMediaPhoto)
+	first.Caption = "caption"
+	media[0] = first
+
+	config := NewMediaGroup(3, media)
+	config.ReplyToMessageID = 9
+
+	messages, err := newTestBot(client).SendAlbum(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 3 || len(messages) != 3 {
+		t.Fatalf("expected 3 media groups, got %d requests", len(requests))
+	}
+
+	for i, expected := range []int{7, 7, 7} {
+		var items []map[string]interface{}
+		if err := json.Unmarshal([]byte(requests[i].values.Get("media")), &items); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(items) != expected {
+			t.Errorf("expected %d items in group %d, got %d", expected, i, len(items))
+		}
+
+		if (i == 0) != (items[0]["caption"] == "caption") {
+			t.Errorf("expected caption only on first item of first group, group %d got %v", i, items[0]["caption"])
+		}
+
+		if (i == 0) != (requests[i].values.Get("reply_to_message_id") == "9") {
+			t.Errorf("expected only first group to be a reply, group %d", i)
+		}
+	}
+}
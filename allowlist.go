@@ -0,0 +1,138 @@
+package tgbotapi
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TelegramSubnets are the networks Telegram sends webhook requests from.
+var TelegramSubnets = []string{
+	"149.154.160.0/20",
+	"91.108.4.0/22",
+}
+
+// IPAllowlist rejects HTTP requests which do not come from one of the
+// allowed networks.
+type IPAllowlist struct {
+	// Allowed are the networks requests may come from.
+	Allowed []*net.IPNet
+	// TrustedProxies are the networks of proxies in front of the server,
+	// such as a load balancer. The X-Forwarded-For header is only used when
+	// a request comes from one of these.
+	//
+	// optional
+	TrustedProxies []*net.IPNet
+}
+
+// NewIPAllowlist creates a new IPAllowlist.
+//
+// allowed and trustedProxies are in CIDR notation, such as "10.0.0.0/8".
+func NewIPAllowlist(allowed []string, trustedProxies []string) (*IPAllowlist, error) {
+	allowedNets, err := parseCIDRs(allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyNets, err := parseCIDRs(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IPAllowlist{
+		Allowed:        allowedNets,
+		TrustedProxies: proxyNets,
+	}, nil
+}
+
+// NewTelegramIPAllowlist creates a new IPAllowlist only allowing requests from
+// TelegramSubnets.
+//
+// trustedProxies are in CIDR notation, such as "10.0.0.0/8".
+func NewTelegramIPAllowlist(trustedProxies ...string) (*IPAllowlist, error) {
+	return NewIPAllowlist(TelegramSubnets, trustedProxies)
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns the IP address a request originates from, or nil if it
+// cannot be determined.
+//
+// If the request comes from a trusted proxy, X-Forwarded-For is read from
+// right to left and the first address which is not a trusted proxy is used.
+func (a *IPAllowlist) ClientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(a.TrustedProxies, ip) {
+		return ip
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip = net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			return nil
+		}
+
+		if !containsIP(a.TrustedProxies, ip) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
+// IsAllowed returns true if the request comes from an allowed network.
+func (a *IPAllowlist) IsAllowed(r *http.Request) bool {
+	ip := a.ClientIP(r)
+
+	return ip != nil && containsIP(a.Allowed, ip)
+}
+
+// Wrap returns a http.Handler which responds with 403 Forbidden to requests
+// not coming from an allowed network, and calls next for all others.
+func (a *IPAllowlist) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.IsAllowed(r) {
+			writeForbidden(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeForbidden(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
@@ -0,0 +1,94 @@
+package tgbotapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newAllowlistRequest(remoteAddr string, forwardedFor ...string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	r.RemoteAddr = remoteAddr
+
+	for _, header := range forwardedFor {
+		r.Header.Add("X-Forwarded-For", header)
+	}
+
+	return r
+}
+
+func TestIPAllowlistDirect(t *testing.T) {
+	allowlist, err := NewTelegramIPAllowlist()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !allowlist.IsAllowed(newAllowlistRequest("149.154.167.220:443")) {
+		t.Error("expected Telegram address to be allowed")
+	}
+
+	if !allowlist.IsAllowed(newAllowlistRequest("91.108.6.1:443")) {
+		t.Error("expected Telegram address to be allowed")
+	}
+
+	if allowlist.IsAllowed(newAllowlistRequest("203.0.113.1:443")) {
+		t.Error("expected other address to be rejected")
+	}
+
+	if allowlist.IsAllowed(newAllowlistRequest("203.0.113.1:443", "149.154.167.220")) {
+		t.Error("expected X-Forwarded-For from untrusted source to be ignored")
+	}
+}
+
+func TestIPAllowlistTrustedProxy(t *testing.T) {
+	allowlist, err := NewTelegramIPAllowlist("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !allowlist.IsAllowed(newAllowlistRequest("10.0.0.1:80", "149.154.167.220")) {
+		t.Error("expected forwarded Telegram address to be allowed")
+	}
+
+	if !allowlist.IsAllowed(newAllowlistRequest("10.0.0.1:80", "203.0.113.1, 149.154.167.220, 10.0.0.2")) {
+		t.Error("expected rightmost untrusted address to be used")
+	}
+
+	if allowlist.IsAllowed(newAllowlistRequest("10.0.0.1:80", "149.154.167.220, 203.0.113.1")) {
+		t.Error("expected spoofed leftmost address to be ignored")
+	}
+
+	if allowlist.IsAllowed(newAllowlistRequest("10.0.0.1:80")) {
+		t.Error("expected proxy address itself to be rejected")
+	}
+}
+
+func TestWebhookHandlerAllowlist(t *testing.T) {
+	allowlist, _ := NewTelegramIPAllowlist()
+
+	h := NewWebhookHandler(newTestBot(&testClient{}), func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		return nil, nil
+	})
+	h.Allowlist = allowlist
+
+	r := newWebhookRequest(testWebhookUpdate)
+	r.RemoteAddr = "203.0.113.1:443"
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected forbidden, got %d", w.Code)
+	}
+
+	r = newWebhookRequest(testWebhookUpdate)
+	r.RemoteAddr = "149.154.167.220:443"
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected ok, got %d", w.Code)
+	}
+}
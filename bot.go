@@ -24,6 +24,10 @@ type BotAPI struct {
 	Token  string `json:"token"`
 	Debug  bool   `json:"debug"`
 	Buffer int    `json:"buffer"`
+	// CheckFormatting makes Request and WebhookHandler responses validate
+	// text and captions against their parse mode before sending, returning a
+	// FormattingError instead of letting Telegram fail to parse them.
+	CheckFormatting bool `json:"check_formatting"`
 
 	Self            User       `json:"-"`
 	Client          HTTPClient `json:"-"`
@@ -174,46 +178,8 @@ func (bot *BotAPI) UploadFiles(endpoint string, params Params, files []RequestFi
 		defer w.Close()
 		defer m.Close()
 
-		for field, value := range params {
-			if err := m.WriteField(field, value); err != nil {
-				w.CloseWithError(err)
-				return
-			}
-		}
-
-		for _, file := range files {
-			if file.Data.NeedsUpload() {
-				name, reader, err := file.Data.UploadData()
-				if err != nil {
-					w.CloseWithError(err)
-					return
-				}
-
-				part, err := m.CreateFormFile(file.Name, name)
-				if err != nil {
-					w.CloseWithError(err)
-					return
-				}
-
-				if _, err := io.Copy(part, reader); err != nil {
-					w.CloseWithError(err)
-					return
-				}
-
-				if closer, ok := reader.(io.ReadCloser); ok {
-					if err = closer.Close(); err != nil {
-						w.CloseWithError(err)
-						return
-					}
-				}
-			} else {
-				value := file.Data.SendData()
-
-				if err := m.WriteField(file.Name, value); err != nil {
-					w.CloseWithError(err)
-					return
-				}
-			}
+		if err := writeMultipart(m, params, files); err != nil {
+			w.CloseWithError(err)
 		}
 	}()
 
@@ -262,6 +228,48 @@ func (bot *BotAPI) UploadFiles(endpoint string, params Params, files []RequestFi
 	return &apiResp, nil
 }
 
+// writeMultipart writes params and files to a multipart writer. Files which
+// do not need to be uploaded are written as regular fields.
+func writeMultipart(m *multipart.Writer, params Params, files []RequestFile) error {
+	for field, value := range params {
+		if err := m.WriteField(field, value); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		if file.Data.NeedsUpload() {
+			name, reader, err := file.Data.UploadData()
+			if err != nil {
+				return err
+			}
+
+			part, err := m.CreateFormFile(file.Name, name)
+			if err != nil {
+				return err
+			}
+
+			if _, err := io.Copy(part, reader); err != nil {
+				return err
+			}
+
+			if closer, ok := reader.(io.ReadCloser); ok {
+				if err = closer.Close(); err != nil {
+					return err
+				}
+			}
+		} else {
+			value := file.Data.SendData()
+
+			if err := m.WriteField(file.Name, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // GetFileDirectURL returns direct URL to file
 //
 // It requires the FileID.
@@ -316,6 +324,12 @@ func (bot *BotAPI) Request(c Chattable) (*APIResponse, error) {
 		return nil, err
 	}
 
+	if bot.CheckFormatting {
+		if err := checkFormatting(params); err != nil {
+			return nil, err
+		}
+	}
+
 	if t, ok := c.(Fileable); ok {
 		files := t.files()
 
@@ -349,6 +363,51 @@ func (bot *BotAPI) Send(c Chattable) (Message, error) {
 	return message, err
 }
 
+// SendLong sends a message with text of any length as several messages and
+// returns them.
+//
+// The text is split with SplitText into chunks of MaxMessageTextLength.
+// Only the first message is sent as a reply, and only the last one has the
+// ReplyMarkup. Text which yields no chunks, such as only whitespace, is sent
+// unchanged, so the error from Telegram is returned.
+func (bot *BotAPI) SendLong(config MessageConfig) ([]Message, error) {
+	chunks := SplitText(config.Text, config.ParseMode, config.Entities, MaxMessageTextLength)
+
+	if len(chunks) == 0 {
+		message, err := bot.Send(config)
+		if err != nil {
+			return nil, err
+		}
+
+		return []Message{message}, nil
+	}
+
+	var messages []Message
+
+	for i, chunk := range chunks {
+		msg := config
+		msg.Text = chunk.Text
+		msg.Entities = chunk.Entities
+
+		if i > 0 {
+			msg.ReplyToMessageID = 0
+		}
+
+		if i < len(chunks)-1 {
+			msg.ReplyMarkup = nil
+		}
+
+		message, err := bot.Send(msg)
+		if err != nil {
+			return messages, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
 // SendMediaGroup sends a media group and returns the resulting messages.
 func (bot *BotAPI) SendMediaGroup(config MediaGroupConfig) ([]Message, error) {
 	resp, err := bot.Request(config)
@@ -362,6 +421,38 @@ func (bot *BotAPI) SendMediaGroup(config MediaGroupConfig) ([]Message, error) {
 	return messages, err
 }
 
+// SendAlbum sends any number of media as albums and returns the resulting
+// messages.
+//
+// The media are validated with ValidateMediaGroup and split into several
+// media groups of MinMediaGroupSize to MaxMediaGroupSize items, sent in
+// order. Captions stay on the items they were set on, and the message is
+// only sent as a reply in the first media group.
+func (bot *BotAPI) SendAlbum(config MediaGroupConfig) ([]Message, error) {
+	if err := ValidateMediaGroup(config.Media); err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+
+	for i, media := range splitMediaGroup(config.Media) {
+		group := config
+		group.Media = media
+
+		if i > 0 {
+			group.ReplyToMessageID = 0
+		}
+
+		sent, err := bot.SendMediaGroup(group)
+		messages = append(messages, sent...)
+		if err != nil {
+			return messages, err
+		}
+	}
+
+	return messages, nil
+}
+
 // GetUserProfilePhotos gets a user's profile photos.
 //
 // It requires UserID.
@@ -430,34 +521,49 @@ func (bot *BotAPI) GetWebhookInfo() (WebhookInfo, error) {
 func (bot *BotAPI) GetUpdatesChan(config UpdateConfig) UpdatesChannel {
 	ch := make(chan Update, bot.Buffer)
 
-	go func() {
-		for {
-			select {
-			case <-bot.shutdownChannel:
-				close(ch)
-				return
-			default:
-			}
+	go bot.pollUpdates(config, ch, bot.shutdownChannel)
 
-			updates, err := bot.GetUpdates(config)
-			if err != nil {
-				log.Println(err)
-				log.Println("Failed to get updates, retrying in 3 seconds...")
-				time.Sleep(time.Second * 3)
+	return ch
+}
+
+// pollUpdates fetches updates and sends them to ch until stop is closed, at
+// which point ch is closed. It returns the offset of the next update, which
+// acknowledges every update that was sent to ch.
+func (bot *BotAPI) pollUpdates(config UpdateConfig, ch chan<- Update, stop <-chan interface{}) int {
+	defer close(ch)
+
+	for {
+		select {
+		case <-stop:
+			return config.Offset
+		default:
+		}
 
-				continue
+		updates, err := bot.GetUpdates(config)
+		if err != nil {
+			log.Println(err)
+			log.Println("Failed to get updates, retrying in 3 seconds...")
+
+			select {
+			case <-stop:
+				return config.Offset
+			case <-time.After(time.Second * 3):
 			}
 
-			for _, update := range updates {
-				if update.UpdateID >= config.Offset {
+			continue
+		}
+
+		for _, update := range updates {
+			if update.UpdateID >= config.Offset {
+				select {
+				case ch <- update:
 					config.Offset = update.UpdateID + 1
-					ch <- update
+				case <-stop:
+					return config.Offset
 				}
 			}
 		}
-	}()
-
-	return ch
+	}
 }
 
 // StopReceivingUpdates stops the go routine which receives updates
@@ -475,10 +581,7 @@ func (bot *BotAPI) ListenForWebhook(pattern string) UpdatesChannel {
 	http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
 		update, err := bot.HandleUpdate(r)
 		if err != nil {
-			errMsg, _ := json.Marshal(map[string]string{"error": err.Error()})
-			w.WriteHeader(http.StatusBadRequest)
-			w.Header().Set("Content-Type", "application/json")
-			_, _ = w.Write(errMsg)
+			writeWebhookError(w, err)
 			return
 		}
 
@@ -497,10 +600,7 @@ func (bot *BotAPI) ListenForWebhookRespReqFormat(w http.ResponseWriter, r *http.
 
 		update, err := bot.HandleUpdate(r)
 		if err != nil {
-			errMsg, _ := json.Marshal(map[string]string{"error": err.Error()})
-			w.WriteHeader(http.StatusBadRequest)
-			w.Header().Set("Content-Type", "application/json")
-			_, _ = w.Write(errMsg)
+			writeWebhookError(w, err)
 			return
 		}
 
@@ -526,21 +626,66 @@ func (bot *BotAPI) HandleUpdate(r *http.Request) (*Update, error) {
 	return &update, nil
 }
 
+// writeWebhookError responds to a webhook request which could not be parsed.
+func writeWebhookError(w http.ResponseWriter, err error) {
+	errMsg, _ := json.Marshal(map[string]string{"error": err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write(errMsg)
+}
+
 // WriteToHTTPResponse writes the request to the HTTP ResponseWriter.
 //
-// It doesn't support uploading files.
+// If the Chattable has files needing to be uploaded, the response is written
+// as multipart/form-data instead of being URL encoded.
 //
 // See https://core.telegram.org/bots/api#making-requests-when-getting-updates
 // for details.
 func WriteToHTTPResponse(w http.ResponseWriter, c Chattable) error {
+	return writeToHTTPResponse(w, c, false)
+}
+
+// writeToHTTPResponse writes the request to the HTTP ResponseWriter, first
+// validating its formatting if check is set. Telegram does not report errors
+// for requests made in webhook responses, so they are caught before anything
+// is written.
+func writeToHTTPResponse(w http.ResponseWriter, c Chattable, check bool) error {
 	params, err := c.params()
 	if err != nil {
 		return err
 	}
 
+	if check {
+		if err := checkFormatting(params); err != nil {
+			return err
+		}
+	}
+
+	if params == nil {
+		params = make(Params)
+	}
+
 	if t, ok := c.(Fileable); ok {
-		if hasFilesNeedingUpload(t.files()) {
-			return errors.New("unable to use http response to upload files")
+		files := t.files()
+
+		if hasFilesNeedingUpload(files) {
+			m := multipart.NewWriter(w)
+
+			w.Header().Set("Content-Type", m.FormDataContentType())
+
+			if err := m.WriteField("method", c.method()); err != nil {
+				return err
+			}
+
+			if err := writeMultipart(m, params, files); err != nil {
+				return err
+			}
+
+			return m.Close()
+		}
+
+		for _, file := range files {
+			params[file.Name] = file.Data.SendData()
 		}
 	}
 
@@ -739,6 +884,7 @@ func EscapeText(parseMode string, text string) string {
 			"\\(", ")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>",
 			"#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|",
 			"\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+			"\\", "\\\\",
 		)
 	} else {
 		return ""
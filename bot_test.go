@@ -1,6 +1,7 @@
 package tgbotapi
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"testing"
@@ -773,14 +774,13 @@ func ExampleWebhookHandler() {
 		log.Printf("[Telegram callback failed]%s", info.LastErrorMessage)
 	}
 
-	http.HandleFunc("/"+bot.Token, func(w http.ResponseWriter, r *http.Request) {
-		update, err := bot.HandleUpdate(r)
-		if err != nil {
-			log.Printf("%+v\n", err.Error())
-		} else {
-			log.Printf("%+v\n", *update)
+	http.Handle("/"+bot.Token, NewWebhookHandler(bot, func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		if update.Message == nil {
+			return nil, nil
 		}
-	})
+
+		return NewMessage(update.Message.Chat.ID, update.Message.Text), nil
+	}))
 
 	go http.ListenAndServeTLS("0.0.0.0:8443", "cert.pem", "key.pem", nil)
 }
@@ -1048,3 +1048,22 @@ func TestPrepareInputMediaForParams(t *testing.T) {
 		t.Error("Passthrough value was not the same")
 	}
 }
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		parseMode string
+		text      string
+		expected  string
+	}{
+		{ModeHTML, "<b>&", "&lt;b&gt;&amp;"},
+		{ModeMarkdown, "_*`[", "\\_\\*\\`\\["},
+		{ModeMarkdownV2, "a\\b.", "a\\\\b\\."},
+		{"", "text", ""},
+	}
+
+	for _, test := range tests {
+		if escaped := EscapeText(test.parseMode, test.text); escaped != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.parseMode, test.expected, escaped)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package tgbotapi
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MaxCallbackDataLength is the maximum length of callback data in bytes.
+const MaxCallbackDataLength = 64
+
+// Constant values for callback data errors
+const (
+	// ErrCallbackDataTooLong happens when encoded callback data exceeds
+	// MaxCallbackDataLength
+	ErrCallbackDataTooLong = "callback data is longer than 64 bytes"
+	// ErrCallbackNamespace happens when decoding callback data from another
+	// namespace
+	ErrCallbackNamespace = "callback data has a different namespace"
+)
+
+// CallbackCodec encodes structs into compact callback data prefixed with a
+// namespace, so callbacks can be routed by namespace.
+//
+// Exported struct fields are encoded in order, integers as varints and
+// strings with their length, and the result is base64 encoded. Fields may
+// be bools, integers or strings. Fields tagged `callback:"-"` are skipped.
+type CallbackCodec struct {
+	Namespace string
+}
+
+// NewCallbackCodec creates a new CallbackCodec for namespace, which must not
+// contain a colon.
+func NewCallbackCodec(namespace string) CallbackCodec {
+	return CallbackCodec{
+		Namespace: namespace,
+	}
+}
+
+func (c CallbackCodec) prefix() string {
+	return c.Namespace + ":"
+}
+
+// Encode encodes v, a struct or a pointer to one, into callback data. It
+// returns an ErrCallbackDataTooLong error if the result would exceed
+// MaxCallbackDataLength.
+func (c CallbackCodec) Encode(v interface{}) (string, error) {
+	if strings.Contains(c.Namespace, ":") {
+		return "", errors.New("callback namespace must not contain a colon")
+	}
+
+	var payload []byte
+
+	if v != nil {
+		rv := reflect.Indirect(reflect.ValueOf(v))
+		if rv.Kind() != reflect.Struct {
+			return "", errors.New("callback data must be a struct")
+		}
+
+		var buf [binary.MaxVarintLen64]byte
+
+		err := walkCallbackFields(rv, func(field reflect.Value) error {
+			switch field.Kind() {
+			case reflect.Bool:
+				if field.Bool() {
+					payload = append(payload, 1)
+				} else {
+					payload = append(payload, 0)
+				}
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				n := binary.PutVarint(buf[:], field.Int())
+				payload = append(payload, buf[:n]...)
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				n := binary.PutUvarint(buf[:], field.Uint())
+				payload = append(payload, buf[:n]...)
+			case reflect.String:
+				n := binary.PutUvarint(buf[:], uint64(field.Len()))
+				payload = append(payload, buf[:n]...)
+				payload = append(payload, field.String()...)
+			default:
+				return fmt.Errorf("unsupported callback field type %s", field.Type())
+			}
+
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	data := c.prefix() + base64.RawURLEncoding.EncodeToString(payload)
+	if len(data) > MaxCallbackDataLength {
+		return "", errors.New(ErrCallbackDataTooLong)
+	}
+
+	return data, nil
+}
+
+// Decode decodes callback data created by Encode into the struct pointed to
+// by v. It returns an ErrCallbackNamespace error if the data is from
+// another namespace.
+func (c CallbackCodec) Decode(data string, v interface{}) error {
+	if !c.Matches(data) {
+		return errors.New(ErrCallbackNamespace)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(data[len(c.prefix()):])
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("callback data must be decoded into a pointer to a struct")
+	}
+
+	errTruncated := errors.New("callback data is truncated")
+
+	err = walkCallbackFields(rv.Elem(), func(field reflect.Value) error {
+		switch field.Kind() {
+		case reflect.Bool:
+			if len(payload) == 0 {
+				return errTruncated
+			}
+
+			field.SetBool(payload[0] != 0)
+			payload = payload[1:]
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			value, n := binary.Varint(payload)
+			if n <= 0 {
+				return errTruncated
+			}
+
+			field.SetInt(value)
+			payload = payload[n:]
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			value, n := binary.Uvarint(payload)
+			if n <= 0 {
+				return errTruncated
+			}
+
+			field.SetUint(value)
+			payload = payload[n:]
+		case reflect.String:
+			length, n := binary.Uvarint(payload)
+			if n <= 0 || uint64(len(payload)-n) < length {
+				return errTruncated
+			}
+
+			field.SetString(string(payload[n : n+int(length)]))
+			payload = payload[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported callback field type %s", field.Type())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > 0 {
+		return errors.New("callback data has unexpected trailing bytes")
+	}
+
+	return nil
+}
+
+// DecodeUpdate decodes the callback data of an update into the struct
+// pointed to by v.
+func (c CallbackCodec) DecodeUpdate(update Update, v interface{}) error {
+	return c.Decode(update.CallbackData(), v)
+}
+
+// Matches reports whether callback data belongs to the namespace.
+func (c CallbackCodec) Matches(data string) bool {
+	return strings.HasPrefix(data, c.prefix())
+}
+
+// Filter matches callback queries with data in the namespace.
+func (c CallbackCodec) Filter() Filter {
+	return CallbackPrefixFilter(c.prefix())
+}
+
+// Button creates an inline keyboard button with v encoded as callback data.
+func (c CallbackCodec) Button(text string, v interface{}) (InlineKeyboardButton, error) {
+	data, err := c.Encode(v)
+	if err != nil {
+		return InlineKeyboardButton{}, err
+	}
+
+	return NewInlineKeyboardButtonData(text, data), nil
+}
+
+// walkCallbackFields calls fn for every exported field of a struct which is
+// not tagged `callback:"-"`.
+func walkCallbackFields(v reflect.Value, fn func(field reflect.Value) error) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("callback") == "-" {
+			continue
+		}
+
+		if err := fn(v.Field(i)); err != nil {
+			return fmt.Errorf("callback field %s: %w", field.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package tgbotapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type testPageData struct {
+	Page    int
+	Item    uint64
+	Query   string
+	Reverse bool
+	cached  string
+	Skipped string `callback:"-"`
+}
+
+func TestCallbackCodec(t *testing.T) {
+	codec := NewCallbackCodec("page")
+
+	data, err := codec.Encode(testPageData{Page: -3, Item: 1 << 40, Query: "cats", Reverse: true, Skipped: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(data, "page:") || len(data) > MaxCallbackDataLength {
+		t.Errorf("unexpected callback data %q", data)
+	}
+
+	var decoded testPageData
+	if err := codec.DecodeUpdate(newTestCallbackUpdate(data), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Page != -3 || decoded.Item != 1<<40 || decoded.Query != "cats" || !decoded.Reverse || decoded.Skipped != "" {
+		t.Errorf("unexpected decoded data %+v", decoded)
+	}
+
+	if err := NewCallbackCodec("other").Decode(data, &decoded); err == nil || err.Error() != ErrCallbackNamespace {
+		t.Errorf("expected namespace error, got %v", err)
+	}
+
+	if err := codec.Decode(data[:len(data)-3], &decoded); err == nil {
+		t.Error("expected truncated data to fail")
+	}
+
+	if _, err := codec.Encode(testPageData{Query: strings.Repeat("a", 50)}); err == nil || err.Error() != ErrCallbackDataTooLong {
+		t.Errorf("expected data too long error, got %v", err)
+	}
+}
+
+func TestDispatcherCallbackCodec(t *testing.T) {
+	var called []string
+
+	codec := NewCallbackCodec("page")
+	data, _ := codec.Encode(testPageData{Page: 2})
+
+	d := NewDispatcher()
+	d.HandleCallbackCodec(NewCallbackCodec("pager"), namedHandler("pager", &called))
+	d.HandleCallbackCodec(codec, namedHandler("page", &called))
+
+	d.Dispatch(context.Background(), newTestBot(&testClient{}), newTestCallbackUpdate(data))
+
+	if len(called) != 1 || called[0] != "page" {
+		t.Errorf("expected page handler, got %v", called)
+	}
+}
@@ -0,0 +1,346 @@
+package tgbotapi
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Constant values for WebhookCertConfig.KeyType
+const (
+	CertKeyECDSA = "ecdsa"
+	CertKeyRSA   = "rsa"
+)
+
+// Default values for WebhookCertConfig
+const (
+	DefaultCertValidity    = 365 * 24 * time.Hour
+	DefaultCertRenewBefore = 30 * 24 * time.Hour
+)
+
+// WebhookCertConfig contains information about a self-signed certificate for
+// a webhook.
+type WebhookCertConfig struct {
+	// Host is the IP address or hostname of the webhook. It must match the
+	// host of the webhook URL.
+	Host string
+	// KeyType is either CertKeyECDSA or CertKeyRSA. Defaults to CertKeyECDSA.
+	KeyType string
+	// Validity is how long a generated certificate is valid for. Defaults to
+	// DefaultCertValidity.
+	Validity time.Duration
+	// RenewBefore is how long before expiry a certificate is replaced. It
+	// must be shorter than Validity. Defaults to DefaultCertRenewBefore.
+	RenewBefore time.Duration
+	// CertFile and KeyFile are paths to persist the certificate and key to
+	// as PEM. If they exist and are still valid, they are loaded instead of
+	// generating a new certificate.
+	//
+	// optional
+	CertFile string
+	KeyFile  string
+}
+
+// NewWebhookCertConfig creates a WebhookCertConfig with default values.
+//
+// host is the IP address or hostname of the webhook.
+func NewWebhookCertConfig(host string) WebhookCertConfig {
+	return WebhookCertConfig{
+		Host:        host,
+		KeyType:     CertKeyECDSA,
+		Validity:    DefaultCertValidity,
+		RenewBefore: DefaultCertRenewBefore,
+	}
+}
+
+// WebhookCert is a self-signed certificate for use with a webhook.
+type WebhookCert struct {
+	// CertPEM is the PEM encoded certificate.
+	CertPEM []byte
+	// KeyPEM is the PEM encoded private key.
+	KeyPEM []byte
+	// Certificate is the parsed certificate and key.
+	Certificate tls.Certificate
+	// NotAfter is when the certificate expires.
+	NotAfter time.Time
+}
+
+// NewWebhookCert loads the certificate in config.CertFile and config.KeyFile
+// if it exists and is valid for config.Host for longer than
+// config.RenewBefore. Otherwise, a new certificate is generated and saved to
+// those files if set.
+//
+// config.Validity must be longer than config.RenewBefore.
+func NewWebhookCert(config WebhookCertConfig) (*WebhookCert, error) {
+	if config.Host == "" {
+		return nil, errors.New("certificate host is empty")
+	}
+
+	// A certificate renewed before it is even issued would be replaced on
+	// every start and rotation.
+	if config.validity() <= config.renewBefore() {
+		return nil, errors.New("certificate validity must be longer than RenewBefore")
+	}
+
+	if config.CertFile != "" && config.KeyFile != "" {
+		// A certificate which fails to load, such as one for a different
+		// host, is replaced like an expiring one.
+		cert, err := loadWebhookCert(config)
+		if err == nil && !cert.NeedsRenewal(config.renewBefore()) {
+			return cert, nil
+		}
+	}
+
+	cert, err := generateWebhookCert(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.CertFile != "" && config.KeyFile != "" {
+		if err := cert.Save(config.CertFile, config.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return cert, nil
+}
+
+// File returns the certificate for use as WebhookConfig.Certificate.
+func (c *WebhookCert) File() RequestFileData {
+	return FileBytes{
+		Name:  "cert.pem",
+		Bytes: c.CertPEM,
+	}
+}
+
+// TLSConfig returns a tls.Config serving the certificate.
+func (c *WebhookCert) TLSConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{c.Certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// NeedsRenewal returns true if the certificate expires within before.
+func (c *WebhookCert) NeedsRenewal(before time.Duration) bool {
+	return time.Now().Add(before).After(c.NotAfter)
+}
+
+// Save writes the certificate and key to files as PEM.
+func (c *WebhookCert) Save(certFile, keyFile string) error {
+	if err := os.WriteFile(keyFile, c.KeyPEM, 0600); err != nil {
+		return err
+	}
+
+	return os.WriteFile(certFile, c.CertPEM, 0644)
+}
+
+func (config WebhookCertConfig) validity() time.Duration {
+	if config.Validity == 0 {
+		return DefaultCertValidity
+	}
+
+	return config.Validity
+}
+
+func (config WebhookCertConfig) renewBefore() time.Duration {
+	if config.RenewBefore == 0 {
+		return DefaultCertRenewBefore
+	}
+
+	return config.RenewBefore
+}
+
+func loadWebhookCert(config WebhookCertConfig) (*WebhookCert, error) {
+	certPEM, err := os.ReadFile(config.CertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := os.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseWebhookCert(config.Host, certPEM, keyPEM)
+}
+
+func parseWebhookCert(host string, certPEM, keyPEM []byte) (*WebhookCert, error) {
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := leaf.VerifyHostname(host); err != nil {
+		return nil, err
+	}
+
+	certificate.Leaf = leaf
+
+	return &WebhookCert{
+		CertPEM:     certPEM,
+		KeyPEM:      keyPEM,
+		Certificate: certificate,
+		NotAfter:    leaf.NotAfter,
+	}, nil
+}
+
+func generateWebhookCert(config WebhookCertConfig) (*WebhookCert, error) {
+	var (
+		key      crypto.Signer
+		keyUsage = x509.KeyUsageDigitalSignature
+		err      error
+	)
+
+	switch config.KeyType {
+	case CertKeyECDSA, "":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case CertKeyRSA:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	default:
+		return nil, errors.New("unknown certificate key type: " + config.KeyType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	validity := config.validity()
+	now := time.Now()
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: config.Host},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	if ip := net.ParseIP(config.Host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{config.Host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	return parseWebhookCert(config.Host, certPEM, keyPEM)
+}
+
+// WebhookCertManager keeps a self-signed webhook certificate valid by
+// replacing it before it expires.
+type WebhookCertManager struct {
+	config WebhookCertConfig
+
+	mu   sync.RWMutex
+	cert *WebhookCert
+}
+
+// NewWebhookCertManager creates a new WebhookCertManager, loading or
+// generating the initial certificate.
+func NewWebhookCertManager(config WebhookCertConfig) (*WebhookCertManager, error) {
+	cert, err := NewWebhookCert(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookCertManager{
+		config: config,
+		cert:   cert,
+	}, nil
+}
+
+// Cert returns the current certificate.
+func (m *WebhookCertManager) Cert() *WebhookCert {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.cert
+}
+
+// TLSConfig returns a tls.Config which always serves the current
+// certificate.
+func (m *WebhookCertManager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &m.Cert().Certificate, nil
+		},
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// Rotate replaces the certificate if it expires within RenewBefore, and
+// returns true if it did.
+//
+// Telegram has to be given the new certificate, so the webhook must be set
+// again with the new Cert().File() after a rotation.
+func (m *WebhookCertManager) Rotate() (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.cert.NeedsRenewal(m.config.renewBefore()) {
+		return false, nil
+	}
+
+	cert, err := generateWebhookCert(m.config)
+	if err != nil {
+		return false, err
+	}
+
+	if m.config.CertFile != "" && m.config.KeyFile != "" {
+		if err := cert.Save(m.config.CertFile, m.config.KeyFile); err != nil {
+			return false, err
+		}
+	}
+
+	m.cert = cert
+
+	return true, nil
+}
+
+// SetWebhook sets the webhook at link using the current certificate.
+func (m *WebhookCertManager) SetWebhook(bot *BotAPI, link string) error {
+	webhook, err := NewWebhookWithCert(link, m.Cert().File())
+	if err != nil {
+		return err
+	}
+
+	_, err = bot.Request(webhook)
+
+	return err
+}
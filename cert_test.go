@@ -0,0 +1,137 @@
+package tgbotapi
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewWebhookCertECDSA(t *testing.T) {
+	cert, err := NewWebhookCert(NewWebhookCertConfig("203.0.113.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf := cert.Certificate.Leaf
+	if _, ok := leaf.PublicKey.(*ecdsa.PublicKey); !ok {
+		t.Errorf("expected ECDSA key, got %T", leaf.PublicKey)
+	}
+
+	if len(leaf.IPAddresses) != 1 || leaf.IPAddresses[0].String() != "203.0.113.1" {
+		t.Errorf("unexpected IP addresses: %v", leaf.IPAddresses)
+	}
+
+	if leaf.Subject.CommonName != "203.0.113.1" {
+		t.Errorf("unexpected common name: %s", leaf.Subject.CommonName)
+	}
+
+	file, ok := cert.File().(FileBytes)
+	if !ok || !bytes.Equal(file.Bytes, cert.CertPEM) {
+		t.Error("expected File to contain the certificate")
+	}
+
+	if len(cert.TLSConfig().Certificates) != 1 {
+		t.Error("expected TLSConfig to contain the certificate")
+	}
+}
+
+func TestNewWebhookCertRSA(t *testing.T) {
+	config := NewWebhookCertConfig("example.com")
+	config.KeyType = CertKeyRSA
+
+	cert, err := NewWebhookCert(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf := cert.Certificate.Leaf
+	if _, ok := leaf.PublicKey.(*rsa.PublicKey); !ok {
+		t.Errorf("expected RSA key, got %T", leaf.PublicKey)
+	}
+
+	if err := leaf.VerifyHostname("example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewWebhookCertPersisted(t *testing.T) {
+	dir := t.TempDir()
+
+	config := NewWebhookCertConfig("example.com")
+	config.CertFile = filepath.Join(dir, "cert.pem")
+	config.KeyFile = filepath.Join(dir, "key.pem")
+
+	first, err := NewWebhookCert(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewWebhookCert(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(first.CertPEM, second.CertPEM) {
+		t.Error("expected persisted certificate to be loaded")
+	}
+
+	config.Host = "example.org"
+
+	third, err := NewWebhookCert(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(first.CertPEM, third.CertPEM) {
+		t.Error("expected certificate for different host to be replaced")
+	}
+}
+
+func TestWebhookCertManagerRotate(t *testing.T) {
+	config := NewWebhookCertConfig("example.com")
+	config.Validity = time.Hour
+	config.RenewBefore = time.Minute
+
+	manager, err := NewWebhookCertManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated, err := manager.Rotate(); err != nil || rotated {
+		t.Errorf("expected no rotation, got %v, %v", rotated, err)
+	}
+
+	old := manager.Cert()
+	expiring := *old
+	expiring.NotAfter = time.Now()
+	manager.cert = &expiring
+
+	if rotated, err := manager.Rotate(); err != nil || !rotated {
+		t.Errorf("expected rotation, got %v, %v", rotated, err)
+	}
+
+	tlsCert, err := manager.TLSConfig().GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(tlsCert.Certificate[0], old.Certificate.Certificate[0]) {
+		t.Error("expected TLSConfig to serve the rotated certificate")
+	}
+}
+
+func TestNewWebhookCertRenewBefore(t *testing.T) {
+	config := NewWebhookCertConfig("example.com")
+	config.Validity = 7 * 24 * time.Hour
+
+	if _, err := NewWebhookCert(config); err == nil {
+		t.Error("expected error for validity shorter than RenewBefore")
+	}
+
+	if _, err := NewWebhookCertManager(config); err == nil {
+		t.Error("expected error for validity shorter than RenewBefore")
+	}
+}
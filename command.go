@@ -0,0 +1,415 @@
+package tgbotapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Constant values for command parsing errors
+const (
+	// ErrCommandNotForBot happens when a command mentions another bot
+	ErrCommandNotForBot = "command is addressed to another bot"
+	// ErrUnterminatedQuote happens when command arguments contain an
+	// unclosed quote
+	ErrUnterminatedQuote = "unterminated quote in command arguments"
+)
+
+// ParsedCommand is a bot command and its arguments found in a message.
+type ParsedCommand struct {
+	// Name is the command without the leading slash or bot mention.
+	Name string
+	// Mention is the bot username the command was addressed to, without the
+	// @.
+	//
+	// optional
+	Mention string
+	// RawArgs is the text following the command.
+	RawArgs string
+	// Args are the arguments, split like a shell would.
+	Args []string
+}
+
+// ParseCommand finds the first bot command in the text or caption of a
+// message, which does not need to be at the start, and splits its arguments.
+//
+// It returns nil if the message does not contain a command. If the command
+// mentions a bot other than botUsername, an ErrCommandNotForBot error is
+// returned.
+func ParseCommand(message *Message, botUsername string) (*ParsedCommand, error) {
+	if message == nil {
+		return nil, nil
+	}
+
+	raw, entities := message.textEntities()
+	text := utf16.Encode([]rune(raw))
+
+	for _, entity := range entities {
+		if !entity.IsCommand() {
+			continue
+		}
+
+		end := entity.Offset + entity.Length
+		if entity.Offset < 0 || end > len(text) {
+			continue
+		}
+
+		command := strings.TrimPrefix(entityText(raw, entity), "/")
+
+		cmd := &ParsedCommand{Name: command}
+		if i := strings.Index(command, "@"); i != -1 {
+			cmd.Name = command[:i]
+			cmd.Mention = command[i+1:]
+		}
+
+		if cmd.Mention != "" && !strings.EqualFold(cmd.Mention, botUsername) {
+			return nil, errors.New(ErrCommandNotForBot)
+		}
+
+		cmd.RawArgs = strings.TrimSpace(string(utf16.Decode(text[end:])))
+
+		args, err := SplitCommandArgs(cmd.RawArgs)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd.Args = args
+
+		return cmd, nil
+	}
+
+	return nil, nil
+}
+
+// SplitCommandArgs splits text into arguments like a shell would.
+//
+// Arguments are separated by whitespace. Single quotes keep text as is,
+// double quotes allow backslash escapes, and a backslash outside quotes
+// escapes the next character.
+func SplitCommandArgs(text string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range text {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.New(ErrUnterminatedQuote)
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// commandArg describes a struct field bound to a command argument.
+type commandArg struct {
+	index    int
+	name     string
+	flag     bool
+	optional bool
+	help     string
+	typ      reflect.Type
+}
+
+// commandArgs returns the arguments described by the struct type t.
+//
+// Fields tagged `arg:"name"` are positional arguments in the order they are
+// declared, and fields tagged `flag:"name"` are --name flags. Positional
+// arguments may be marked `arg:"name,optional"`. A `help:"..."` tag
+// describes the argument in usage text.
+func commandArgs(t reflect.Type) ([]commandArg, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("command arguments must be bound to a struct")
+	}
+
+	var args []commandArg
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		arg := commandArg{
+			index: i,
+			help:  field.Tag.Get("help"),
+			typ:   field.Type,
+		}
+
+		if name, ok := field.Tag.Lookup("flag"); ok {
+			arg.name = name
+			arg.flag = true
+		} else if tag, ok := field.Tag.Lookup("arg"); ok {
+			parts := strings.Split(tag, ",")
+			arg.name = parts[0]
+			arg.optional = len(parts) > 1 && parts[1] == "optional"
+		} else {
+			continue
+		}
+
+		if arg.name == "" {
+			arg.name = strings.ToLower(field.Name)
+		}
+
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// Bind stores the command arguments in the struct pointed to by v.
+//
+// Fields tagged `arg:"name"` receive positional arguments in the order they
+// are declared. A []string positional field receives all remaining
+// arguments. Fields tagged `flag:"name"` are set by --name value or
+// --name=value, and bool flags by --name alone. Fields may be strings,
+// bools, integers, floats or time.Duration.
+func (c *ParsedCommand) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("command arguments must be bound to a pointer to a struct")
+	}
+
+	rv = rv.Elem()
+
+	args, err := commandArgs(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	flags := make(map[string]commandArg)
+	var positional []commandArg
+
+	for _, arg := range args {
+		if arg.flag {
+			flags[arg.name] = arg
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+
+	var values []string
+	for i := 0; i < len(c.Args); i++ {
+		value := c.Args[i]
+
+		if value == "--" {
+			values = append(values, c.Args[i+1:]...)
+			break
+		}
+
+		if !strings.HasPrefix(value, "--") {
+			values = append(values, value)
+			continue
+		}
+
+		name := value[2:]
+		flagValue, hasValue := "", false
+		if j := strings.Index(name, "="); j != -1 {
+			name, flagValue, hasValue = name[:j], name[j+1:], true
+		}
+
+		arg, ok := flags[name]
+		if !ok {
+			return fmt.Errorf("unknown flag --%s", name)
+		}
+
+		if !hasValue {
+			if arg.typ.Kind() == reflect.Bool {
+				flagValue = "true"
+			} else if i+1 < len(c.Args) {
+				i++
+				flagValue = c.Args[i]
+			} else {
+				return fmt.Errorf("flag --%s needs a value", name)
+			}
+		}
+
+		if err := setCommandArg(rv.Field(arg.index), flagValue); err != nil {
+			return fmt.Errorf("invalid value for --%s: %w", name, err)
+		}
+	}
+
+	for _, arg := range positional {
+		field := rv.Field(arg.index)
+
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+			if len(values) == 0 && !arg.optional {
+				return fmt.Errorf("missing argument <%s>", arg.name)
+			}
+
+			field.Set(reflect.ValueOf(append([]string{}, values...)))
+			values = nil
+			continue
+		}
+
+		if len(values) == 0 {
+			if arg.optional {
+				continue
+			}
+
+			return fmt.Errorf("missing argument <%s>", arg.name)
+		}
+
+		if err := setCommandArg(field, values[0]); err != nil {
+			return fmt.Errorf("invalid value for <%s>: %w", arg.name, err)
+		}
+
+		values = values[1:]
+	}
+
+	if len(values) > 0 {
+		return fmt.Errorf("unexpected argument %q", values[0])
+	}
+
+	return nil
+}
+
+func setCommandArg(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+// CommandUsage returns usage text for a command with arguments described by
+// the struct v, as used by ParsedCommand.Bind.
+func CommandUsage(command string, v interface{}) string {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return "/" + command
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	args, err := commandArgs(t)
+	if err != nil {
+		return "/" + command
+	}
+
+	var usage strings.Builder
+	usage.WriteString("/" + command)
+
+	for _, arg := range args {
+		var s string
+
+		switch {
+		case arg.flag && arg.typ.Kind() == reflect.Bool:
+			s = "[--" + arg.name + "]"
+		case arg.flag:
+			s = "[--" + arg.name + " <" + arg.name + ">]"
+		case arg.typ.Kind() == reflect.Slice:
+			s = "<" + arg.name + "...>"
+		default:
+			s = "<" + arg.name + ">"
+		}
+
+		if !arg.flag && arg.optional {
+			s = "[" + s + "]"
+		}
+
+		usage.WriteString(" " + s)
+	}
+
+	for _, arg := range args {
+		if arg.help == "" {
+			continue
+		}
+
+		name := "<" + arg.name + ">"
+		if arg.flag {
+			name = "--" + arg.name
+		}
+
+		usage.WriteString("\n  " + name + "  " + arg.help)
+	}
+
+	return usage.String()
+}
@@ -0,0 +1,100 @@
+package tgbotapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitCommandArgs(t *testing.T) {
+	args, err := SplitCommandArgs(`one "two three" 'four "five"' six\ seven "a\"b"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"one", "two three", `four "five"`, "six seven", `a"b`}
+	if len(args) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, args)
+	}
+
+	for i := range expected {
+		if args[i] != expected[i] {
+			t.Fatalf("expected %q, got %q", expected, args)
+		}
+	}
+
+	if _, err := SplitCommandArgs(`"open`); err == nil || err.Error() != ErrUnterminatedQuote {
+		t.Errorf("expected unterminated quote error, got %v", err)
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	message := &Message{
+		Text: "👋 hi /ban@test_bot bob 'spam bot'",
+		Entities: []MessageEntity{
+			{Type: "bold", Offset: 0, Length: 2},
+			{Type: "bot_command", Offset: 6, Length: 13},
+		},
+	}
+
+	cmd, err := ParseCommand(message, "test_bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Name != "ban" || cmd.Mention != "test_bot" || len(cmd.Args) != 2 || cmd.Args[1] != "spam bot" {
+		t.Errorf("unexpected command %+v", cmd)
+	}
+
+	if _, err := ParseCommand(message, "other_bot"); err == nil || err.Error() != ErrCommandNotForBot {
+		t.Errorf("expected command for another bot to be rejected, got %v", err)
+	}
+
+	if cmd, err := ParseCommand(&Message{Text: "hello"}, "test_bot"); cmd != nil || err != nil {
+		t.Errorf("expected no command, got %v, %v", cmd, err)
+	}
+}
+
+type testBanArgs struct {
+	User     string        `arg:"user" help:"user to ban"`
+	Reason   []string      `arg:"reason,optional"`
+	Duration time.Duration `flag:"for" help:"how long to ban for"`
+	Silent   bool          `flag:"silent"`
+}
+
+func TestParsedCommandBind(t *testing.T) {
+	cmd := &ParsedCommand{Name: "ban", Args: []string{"bob", "--for", "1h", "spam", "--silent", "links"}}
+
+	var args testBanArgs
+	if err := cmd.Bind(&args); err != nil {
+		t.Fatal(err)
+	}
+
+	if args.User != "bob" || args.Duration != time.Hour || !args.Silent || len(args.Reason) != 2 || args.Reason[1] != "links" {
+		t.Errorf("unexpected arguments %+v", args)
+	}
+
+	for _, bad := range [][]string{
+		{},
+		{"bob", "--unknown"},
+		{"bob", "--for=soon"},
+		{"bob", "--for"},
+	} {
+		if err := (&ParsedCommand{Args: bad}).Bind(&testBanArgs{}); err == nil {
+			t.Errorf("expected error binding %q", bad)
+		}
+	}
+
+	usage := CommandUsage("ban", testBanArgs{})
+	expected := "/ban <user> [<reason...>] [--for <for>] [--silent]\n  <user>  user to ban\n  --for  how long to ban for"
+	if usage != expected {
+		t.Errorf("expected usage %q, got %q", expected, usage)
+	}
+
+	if err := cmd.Bind((*testBanArgs)(nil)); err == nil {
+		t.Error("expected error binding to a nil pointer")
+	}
+
+	if usage := CommandUsage("ban", nil); usage != "/ban" {
+		t.Errorf("expected bare usage for nil, got %q", usage)
+	}
+}
@@ -0,0 +1,1129 @@
+package tgbotapi
+
+import (
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseCommonMark converts CommonMark text into text with entities.
+//
+// Emphasis, strikethrough, links, code and blockquotes are converted to
+// entities. Constructs Telegram has no formatting for are degraded: headings
+// become bold lines, list items are prefixed with bullets or numbers, images
+// become links to the image and thematic breaks become a line of dashes.
+// Raw HTML is kept as text.
+func ParseCommonMark(markdown string) *TextBuilder {
+	c := commonMarkConverter{
+		builder: NewTextBuilder(),
+		refs:    make(map[string]string),
+	}
+
+	markdown = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(markdown)
+
+	c.render(c.parseBlocks(strings.Split(markdown, "\n")), 0, false, "\n\n")
+
+	return c.builder
+}
+
+// CommonMarkToMarkdownV2 converts CommonMark text into ModeMarkdownV2 text,
+// degrading constructs Telegram has no formatting for like ParseCommonMark.
+func CommonMarkToMarkdownV2(markdown string) string {
+	b := ParseCommonMark(markdown)
+	return RenderMarkdownV2(b.Text(), b.Entities())
+}
+
+type commonMarkBlockKind int
+
+const (
+	commonMarkParagraph commonMarkBlockKind = iota
+	commonMarkHeading
+	commonMarkCode
+	commonMarkQuote
+	commonMarkList
+	commonMarkThematicBreak
+)
+
+type commonMarkBlock struct {
+	kind commonMarkBlockKind
+	// text is the inline content of paragraphs and headings, or the content
+	// of code blocks.
+	text     string
+	language string
+	children []commonMarkBlock
+	items    [][]commonMarkBlock
+	ordered  bool
+	start    int
+}
+
+type commonMarkConverter struct {
+	builder *TextBuilder
+	// refs are the destinations of link reference definitions, by
+	// normalized label.
+	refs map[string]string
+}
+
+// parseBlocks splits lines into blocks, collecting link reference
+// definitions on the way.
+func (c *commonMarkConverter) parseBlocks(lines []string) []commonMarkBlock {
+	var blocks []commonMarkBlock
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		indent, rest := commonMarkIndent(line)
+
+		if rest == "" {
+			i++
+			continue
+		}
+
+		if indent >= 4 {
+			var code []string
+			for ; i < len(lines); i++ {
+				indent, rest := commonMarkIndent(lines[i])
+				if rest != "" && indent < 4 {
+					break
+				}
+
+				code = append(code, commonMarkStripIndent(lines[i], 4))
+			}
+
+			blocks = append(blocks, commonMarkBlock{
+				kind: commonMarkCode,
+				text: strings.TrimRight(strings.Join(code, "\n"), "\n"),
+			})
+
+			continue
+		}
+
+		if fence, info, ok := commonMarkFence(line); ok {
+			var code []string
+			for i++; i < len(lines); i++ {
+				if commonMarkClosesFence(lines[i], fence) {
+					i++
+					break
+				}
+
+				code = append(code, commonMarkStripIndent(lines[i], indent))
+			}
+
+			block := commonMarkBlock{
+				kind: commonMarkCode,
+				text: strings.Join(code, "\n"),
+			}
+
+			if fields := strings.Fields(info); len(fields) > 0 {
+				block.language = commonMarkUnescape(fields[0])
+			}
+
+			blocks = append(blocks, block)
+
+			continue
+		}
+
+		if text, ok := commonMarkATXHeading(line); ok {
+			blocks = append(blocks, commonMarkBlock{kind: commonMarkHeading, text: text})
+			i++
+			continue
+		}
+
+		if commonMarkIsThematicBreak(line) {
+			blocks = append(blocks, commonMarkBlock{kind: commonMarkThematicBreak})
+			i++
+			continue
+		}
+
+		if strings.HasPrefix(rest, ">") {
+			var quote []string
+			for ; i < len(lines); i++ {
+				indent, rest := commonMarkIndent(lines[i])
+
+				if indent < 4 && strings.HasPrefix(rest, ">") {
+					quote = append(quote, commonMarkStripIndent(rest[1:], 1))
+				} else if rest != "" && strings.TrimSpace(quote[len(quote)-1]) != "" && !commonMarkInterrupts(lines[i]) {
+					// A paragraph continues without a > on every line.
+					quote = append(quote, lines[i])
+				} else {
+					break
+				}
+			}
+
+			blocks = append(blocks, commonMarkBlock{
+				kind:     commonMarkQuote,
+				children: c.parseBlocks(quote),
+			})
+
+			continue
+		}
+
+		if _, ok := commonMarkListItem(line); ok {
+			block, n := c.parseList(lines[i:])
+			blocks = append(blocks, block)
+			i += n
+			continue
+		}
+
+		if c.parseDefinition(line) {
+			i++
+			continue
+		}
+
+		block := commonMarkBlock{kind: commonMarkParagraph}
+
+		var paragraph []string
+		for ; i < len(lines); i++ {
+			_, rest := commonMarkIndent(lines[i])
+			if rest == "" {
+				break
+			}
+
+			if len(paragraph) > 0 {
+				if commonMarkIsSetextUnderline(lines[i]) {
+					block.kind = commonMarkHeading
+					i++
+					break
+				}
+
+				if commonMarkInterrupts(lines[i]) {
+					break
+				}
+			}
+
+			paragraph = append(paragraph, rest)
+		}
+
+		block.text = strings.TrimRight(strings.Join(paragraph, "\n"), " \t")
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+// parseList parses a list starting at the first line, and returns it with
+// the number of lines it spans.
+func (c *commonMarkConverter) parseList(lines []string) (commonMarkBlock, int) {
+	first, _ := commonMarkListItem(lines[0])
+
+	block := commonMarkBlock{
+		kind:    commonMarkList,
+		ordered: first.ordered,
+		start:   first.start,
+	}
+
+	i := 0
+	for i < len(lines) {
+		marker, ok := commonMarkListItem(lines[i])
+		if !ok || marker.ordered != first.ordered || marker.delimiter != first.delimiter || commonMarkIsThematicBreak(lines[i]) {
+			break
+		}
+
+		item := []string{marker.content}
+
+	collect:
+		for i++; i < len(lines); i++ {
+			indent, rest := commonMarkIndent(lines[i])
+
+			switch {
+			case rest == "":
+				item = append(item, "")
+			case indent >= marker.indent:
+				item = append(item, commonMarkStripIndent(lines[i], marker.indent))
+			case strings.TrimSpace(item[len(item)-1]) != "" && !commonMarkInterrupts(lines[i]):
+				if _, ok := commonMarkListItem(lines[i]); ok {
+					break collect
+				}
+
+				// A paragraph continues without being indented.
+				item = append(item, rest)
+			default:
+				break collect
+			}
+		}
+
+		block.items = append(block.items, c.parseBlocks(item))
+	}
+
+	return block, i
+}
+
+// parseDefinition records a link reference definition and reports whether
+// line is one.
+func (c *commonMarkConverter) parseDefinition(line string) bool {
+	indent, rest := commonMarkIndent(line)
+	if indent >= 4 || !strings.HasPrefix(rest, "[") {
+		return false
+	}
+
+	end := strings.Index(rest, "]:")
+	if end < 2 || strings.ContainsAny(rest[1:end], "[]") {
+		return false
+	}
+
+	label := rest[1:end]
+
+	rest = rest[end+2:]
+	rest = rest[commonMarkSpaces(rest):]
+
+	dest, n, ok := commonMarkDestination(rest)
+	if !ok || n == 0 {
+		return false
+	}
+
+	rest = rest[n:]
+	if title := strings.TrimSpace(rest); title != "" {
+		if commonMarkSpaces(rest) == 0 {
+			return false
+		}
+
+		n, ok := commonMarkTitle(title)
+		if !ok || strings.TrimSpace(title[n:]) != "" {
+			return false
+		}
+	}
+
+	key := commonMarkLabel(label)
+	if _, ok := c.refs[key]; !ok {
+		c.refs[key] = dest
+	}
+
+	return true
+}
+
+func (c *commonMarkConverter) render(blocks []commonMarkBlock, depth int, quoted bool, separator string) {
+	for i, block := range blocks {
+		if i > 0 {
+			c.builder.Plain(separator)
+		}
+
+		switch block.kind {
+		case commonMarkParagraph:
+			c.renderInline(c.parseInline(block.text), false)
+		case commonMarkHeading:
+			c.builder.Wrap(MessageEntity{Type: "bold"}, func(*TextBuilder) {
+				c.renderInline(c.parseInline(block.text), false)
+			})
+		case commonMarkCode:
+			c.builder.Pre(block.text, block.language)
+		case commonMarkThematicBreak:
+			c.builder.Plain("———")
+		case commonMarkQuote:
+			// Telegram does not support nested blockquotes.
+			if quoted {
+				c.render(block.children, depth, true, "\n\n")
+				break
+			}
+
+			c.builder.Wrap(MessageEntity{Type: "blockquote"}, func(*TextBuilder) {
+				c.render(block.children, depth, true, "\n\n")
+			})
+		case commonMarkList:
+			for j, item := range block.items {
+				if j > 0 {
+					c.builder.Plain("\n")
+				}
+
+				marker := "• "
+				if block.ordered {
+					marker = strconv.Itoa(block.start+j) + ". "
+				}
+
+				c.builder.Plain(strings.Repeat("  ", depth) + marker)
+
+				// A blockquote has to start on a line of its own.
+				if !quoted && len(item) > 0 && item[0].kind == commonMarkQuote {
+					c.builder.Plain("\n")
+				}
+
+				c.render(item, depth+1, quoted, "\n")
+			}
+		}
+	}
+}
+
+// commonMarkNode is an inline text, an entity containing other nodes, or a
+// run of emphasis delimiters.
+type commonMarkNode struct {
+	text     string
+	entity   *MessageEntity
+	children []commonMarkNode
+
+	delimiter byte
+	// count is the number of delimiters not matched yet, and length the
+	// number of delimiters in the run.
+	count, length     int
+	canOpen, canClose bool
+}
+
+func (c *commonMarkConverter) parseInline(s string) []commonMarkNode {
+	var (
+		nodes []commonMarkNode
+		text  strings.Builder
+	)
+
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, commonMarkNode{text: html.UnescapeString(text.String())})
+			text.Reset()
+		}
+	}
+
+	add := func(node commonMarkNode) {
+		flush()
+		nodes = append(nodes, node)
+	}
+
+	for i := 0; i < len(s); {
+		ch := s[i]
+
+		switch {
+		case ch == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			add(commonMarkNode{text: "\n"})
+			i += 2
+		case ch == '\\' && i+1 < len(s) && commonMarkIsASCIIPunct(s[i+1]):
+			add(commonMarkNode{text: s[i+1 : i+2]})
+			i += 2
+		case ch == '\n':
+			// Two spaces at the end of a line are a hard line break, a
+			// line break alone is a space.
+			line := text.String()
+			trimmed := strings.TrimRight(line, " ")
+
+			text.Reset()
+			text.WriteString(trimmed)
+
+			if len(line)-len(trimmed) >= 2 {
+				add(commonMarkNode{text: "\n"})
+			} else {
+				text.WriteByte(' ')
+			}
+
+			i++
+		case ch == '`':
+			n, end := commonMarkBackticks(s, i)
+			if end < 0 {
+				text.WriteString(s[i : i+n])
+				i += n
+				break
+			}
+
+			code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+			if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+				code = code[1 : len(code)-1]
+			}
+
+			add(commonMarkNode{
+				entity:   &MessageEntity{Type: "code"},
+				children: []commonMarkNode{{text: code}},
+			})
+			i = end + n
+		case ch == '*' || ch == '_' || ch == '~':
+			n := 1
+			for i+n < len(s) && s[i+n] == ch {
+				n++
+			}
+
+			// Only ~~ is strikethrough.
+			if ch == '~' && n != 2 {
+				text.WriteString(s[i : i+n])
+				i += n
+				break
+			}
+
+			left, right, prevPunct, nextPunct := commonMarkFlanking(s, i, i+n)
+
+			node := commonMarkNode{
+				delimiter: ch,
+				count:     n,
+				length:    n,
+				canOpen:   left,
+				canClose:  right,
+			}
+
+			if ch == '_' {
+				node.canOpen = left && (!right || prevPunct)
+				node.canClose = right && (!left || nextPunct)
+			}
+
+			add(node)
+			i += n
+		case ch == '[' || (ch == '!' && i+1 < len(s) && s[i+1] == '['):
+			image := ch == '!'
+
+			start := i
+			if image {
+				start++
+			}
+
+			node, n, ok := c.parseLink(s[start:], image)
+			if !ok {
+				text.WriteByte(ch)
+				i++
+				break
+			}
+
+			add(node)
+			i = start + n
+		case ch == '<':
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				text.WriteByte(ch)
+				i++
+				break
+			}
+
+			target := s[i+1 : i+end]
+
+			switch {
+			case strings.ContainsAny(target, " \t\n<"):
+				text.WriteByte(ch)
+				i++
+				continue
+			case commonMarkIsURI(target):
+				add(commonMarkNode{
+					entity:   &MessageEntity{Type: "text_link", URL: target},
+					children: []commonMarkNode{{text: target}},
+				})
+			case commonMarkIsEmail(target):
+				add(commonMarkNode{text: target})
+			default:
+				text.WriteByte(ch)
+				i++
+				continue
+			}
+
+			i += end + 1
+		default:
+			text.WriteByte(ch)
+			i++
+		}
+	}
+
+	flush()
+
+	return commonMarkEmphasis(nodes)
+}
+
+// parseLink parses an inline link, a reference link or an image starting
+// with the [ at the start of s, and returns it with its length.
+func (c *commonMarkConverter) parseLink(s string, image bool) (commonMarkNode, int, bool) {
+	end := commonMarkLabelEnd(s)
+	if end < 0 {
+		return commonMarkNode{}, 0, false
+	}
+
+	label := s[1:end]
+	rest := s[end+1:]
+	length := end + 1
+
+	dest, n, ok := commonMarkInlineLink(rest)
+	if ok {
+		length += n
+	} else if strings.HasPrefix(rest, "[") {
+		if e := strings.IndexByte(rest, ']'); e >= 0 {
+			ref := rest[1:e]
+			if ref == "" {
+				ref = label
+			}
+
+			dest, ok = c.refs[commonMarkLabel(ref)]
+			if ok {
+				length += e + 1
+			}
+		}
+	}
+
+	if !ok {
+		dest, ok = c.refs[commonMarkLabel(label)]
+		if !ok {
+			return commonMarkNode{}, 0, false
+		}
+	}
+
+	node := commonMarkNode{
+		entity:   &MessageEntity{Type: "text_link", URL: dest},
+		children: c.parseInline(label),
+	}
+
+	if image && strings.TrimSpace(label) == "" {
+		node.children = []commonMarkNode{{text: dest}}
+	}
+
+	return node, length, true
+}
+
+func (c *commonMarkConverter) renderInline(nodes []commonMarkNode, inLink bool) {
+	for _, node := range nodes {
+		switch {
+		case node.delimiter != 0:
+			c.builder.Plain(strings.Repeat(string(node.delimiter), node.count))
+		case node.entity != nil:
+			entity := *node.entity
+			children := node.children
+
+			if entity.Type == "text_link" {
+				// Links can't be nested, and Telegram rejects relative
+				// links, so only their text is kept.
+				if inLink || !commonMarkIsAbsoluteURL(entity.URL) {
+					c.renderInline(children, inLink)
+					break
+				}
+			}
+
+			c.builder.Wrap(entity, func(*TextBuilder) {
+				c.renderInline(children, inLink || entity.Type == "text_link")
+			})
+		default:
+			c.builder.Plain(node.text)
+		}
+	}
+}
+
+// commonMarkEmphasis matches emphasis delimiters, wrapping the nodes between
+// them in entities. Unmatched delimiters are kept as text.
+func commonMarkEmphasis(nodes []commonMarkNode) []commonMarkNode {
+	for closer := 0; closer < len(nodes); closer++ {
+		c := nodes[closer]
+		if c.delimiter == 0 || !c.canClose || c.count == 0 {
+			continue
+		}
+
+		opener := -1
+		for i := closer - 1; i >= 0; i-- {
+			o := nodes[i]
+			if o.delimiter != c.delimiter || !o.canOpen || o.count == 0 {
+				continue
+			}
+
+			// A run which can both open and close can't be matched when
+			// the lengths add up to a multiple of three, unless both are.
+			if (o.canClose || c.canOpen) && (o.length+c.length)%3 == 0 && (o.length%3 != 0 || c.length%3 != 0) {
+				continue
+			}
+
+			opener = i
+			break
+		}
+
+		if opener < 0 {
+			continue
+		}
+
+		use := 1
+		if nodes[opener].count >= 2 && c.count >= 2 {
+			use = 2
+		}
+
+		entity := MessageEntity{Type: "italic"}
+		switch {
+		case c.delimiter == '~':
+			entity.Type = "strikethrough"
+		case use == 2:
+			entity.Type = "bold"
+		}
+
+		wrapped := commonMarkNode{
+			entity:   &entity,
+			children: append([]commonMarkNode(nil), nodes[opener+1:closer]...),
+		}
+
+		nodes[opener].count -= use
+		nodes[closer].count -= use
+
+		rest := append([]commonMarkNode{wrapped}, nodes[closer:]...)
+		nodes = append(nodes[:opener+1], rest...)
+
+		// Continue with the same closer, which may have delimiters left.
+		closer = opener + 1
+	}
+
+	return nodes
+}
+
+// commonMarkFlanking reports whether the delimiter run s[start:end] is left
+// and right flanking, and whether it is preceded and followed by
+// punctuation.
+func commonMarkFlanking(s string, start, end int) (left, right, prevPunct, nextPunct bool) {
+	prev, next := ' ', ' '
+	if start > 0 {
+		prev, _ = utf8.DecodeLastRuneInString(s[:start])
+	}
+
+	if end < len(s) {
+		next, _ = utf8.DecodeRuneInString(s[end:])
+	}
+
+	prevSpace, nextSpace := unicode.IsSpace(prev), unicode.IsSpace(next)
+	prevPunct = unicode.IsPunct(prev) || unicode.IsSymbol(prev)
+	nextPunct = unicode.IsPunct(next) || unicode.IsSymbol(next)
+
+	left = !nextSpace && (!nextPunct || prevSpace || prevPunct)
+	right = !prevSpace && (!prevPunct || nextSpace || nextPunct)
+
+	return left, right, prevPunct, nextPunct
+}
+
+// commonMarkBackticks returns the length of the backtick run at s[i] and the
+// index of the next run of the same length, or -1 if there is none.
+func commonMarkBackticks(s string, i int) (int, int) {
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+
+	for j := i + n; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+
+		m := 0
+		for j+m < len(s) && s[j+m] == '`' {
+			m++
+		}
+
+		if m == n {
+			return n, j
+		}
+
+		j += m
+	}
+
+	return n, -1
+}
+
+// commonMarkLabelEnd returns the index of the ] closing the [ at the start
+// of s, or -1 if there is none.
+func commonMarkLabelEnd(s string) int {
+	depth := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			n, end := commonMarkBackticks(s, i)
+			if end >= 0 {
+				i = end + n - 1
+			} else {
+				i += n - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// commonMarkInlineLink parses (destination "title") at the start of s, and
+// returns the destination and the length of the text parsed.
+func commonMarkInlineLink(s string) (string, int, bool) {
+	if !strings.HasPrefix(s, "(") {
+		return "", 0, false
+	}
+
+	i := 1 + commonMarkSpaces(s[1:])
+
+	dest, n, ok := commonMarkDestination(s[i:])
+	if !ok {
+		return "", 0, false
+	}
+
+	i += n
+
+	j := i + commonMarkSpaces(s[i:])
+	if j > i && j < len(s) && s[j] != ')' {
+		n, ok := commonMarkTitle(s[j:])
+		if !ok {
+			return "", 0, false
+		}
+
+		j += n
+		j += commonMarkSpaces(s[j:])
+	}
+
+	if j >= len(s) || s[j] != ')' {
+		return "", 0, false
+	}
+
+	return dest, j + 1, true
+}
+
+// commonMarkDestination parses a link destination at the start of s, and
+// returns it with the length of the text parsed.
+func commonMarkDestination(s string) (string, int, bool) {
+	if strings.HasPrefix(s, "<") {
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '\n', '<':
+				return "", 0, false
+			case '>':
+				return commonMarkUnescape(s[1:i]), i + 1, true
+			}
+		}
+
+		return "", 0, false
+	}
+
+	depth := 0
+
+	i := 0
+loop:
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				break loop
+			}
+
+			depth--
+		case ' ', '\t', '\n':
+			break loop
+		default:
+			if s[i] < 0x20 {
+				break loop
+			}
+		}
+	}
+
+	if depth != 0 {
+		return "", 0, false
+	}
+
+	return commonMarkUnescape(s[:i]), i, true
+}
+
+// commonMarkTitle returns the length of the link title at the start of s.
+func commonMarkTitle(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	var closer byte
+	switch s[0] {
+	case '"', '\'':
+		closer = s[0]
+	case '(':
+		closer = ')'
+	default:
+		return 0, false
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case closer:
+			return i + 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// commonMarkIndent returns the width of the leading whitespace of line, with
+// tabs expanded to multiples of four columns, and the rest of the line.
+func commonMarkIndent(line string) (int, string) {
+	width := 0
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width, line[i:]
+		}
+	}
+
+	return width, ""
+}
+
+// commonMarkStripIndent removes up to n columns of leading whitespace from
+// line.
+func commonMarkStripIndent(line string, n int) string {
+	width := 0
+
+	for i := 0; i < len(line); i++ {
+		if width >= n {
+			return line[i:]
+		}
+
+		switch line[i] {
+		case ' ':
+			width++
+		case '\t':
+			tab := 4 - width%4
+			if width+tab > n {
+				return strings.Repeat(" ", width+tab-n) + line[i+1:]
+			}
+
+			width += tab
+		default:
+			return line[i:]
+		}
+	}
+
+	return ""
+}
+
+func commonMarkSpaces(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " \t\n"))
+}
+
+// commonMarkFence returns the fence and info string if line opens a fenced
+// code block.
+func commonMarkFence(line string) (string, string, bool) {
+	indent, rest := commonMarkIndent(line)
+	if indent >= 4 {
+		return "", "", false
+	}
+
+	for _, ch := range []string{"`", "~"} {
+		n := len(rest) - len(strings.TrimLeft(rest, ch))
+		if n < 3 {
+			continue
+		}
+
+		info := strings.TrimSpace(rest[n:])
+		if ch == "`" && strings.Contains(info, "`") {
+			return "", "", false
+		}
+
+		return rest[:n], info, true
+	}
+
+	return "", "", false
+}
+
+func commonMarkClosesFence(line, fence string) bool {
+	indent, rest := commonMarkIndent(line)
+	rest = strings.TrimRight(rest, " \t")
+
+	return indent < 4 && len(rest) >= len(fence) && strings.Trim(rest, fence[:1]) == ""
+}
+
+// commonMarkATXHeading returns the text of line if it is a # heading.
+func commonMarkATXHeading(line string) (string, bool) {
+	indent, rest := commonMarkIndent(line)
+	if indent >= 4 || !strings.HasPrefix(rest, "#") {
+		return "", false
+	}
+
+	n := len(rest) - len(strings.TrimLeft(rest, "#"))
+	if n > 6 {
+		return "", false
+	}
+
+	rest = rest[n:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return "", false
+	}
+
+	rest = strings.TrimSpace(rest)
+
+	// A closing sequence of # is only removed after a space.
+	trimmed := strings.TrimRight(rest, "#")
+	if trimmed == "" || strings.HasSuffix(trimmed, " ") || strings.HasSuffix(trimmed, "\t") {
+		rest = strings.TrimSpace(trimmed)
+	}
+
+	return rest, true
+}
+
+func commonMarkIsThematicBreak(line string) bool {
+	indent, rest := commonMarkIndent(line)
+	if indent >= 4 || rest == "" {
+		return false
+	}
+
+	ch := rest[0]
+	if ch != '*' && ch != '-' && ch != '_' {
+		return false
+	}
+
+	n := 0
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case ch:
+			n++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+
+	return n >= 3
+}
+
+// commonMarkIsSetextUnderline reports whether line turns the paragraph
+// before it into a heading.
+func commonMarkIsSetextUnderline(line string) bool {
+	indent, rest := commonMarkIndent(line)
+	rest = strings.TrimRight(rest, " \t")
+
+	if indent >= 4 || rest == "" {
+		return false
+	}
+
+	return strings.Trim(rest, "=") == "" || strings.Trim(rest, "-") == ""
+}
+
+type commonMarkMarker struct {
+	ordered bool
+	// delimiter is the bullet character of bullet lists, or the . or )
+	// after the number of ordered lists.
+	delimiter byte
+	start     int
+	// indent is the column the content of the item starts at.
+	indent  int
+	content string
+}
+
+// commonMarkListItem parses the list marker starting a list item.
+func commonMarkListItem(line string) (commonMarkMarker, bool) {
+	var marker commonMarkMarker
+
+	indent, rest := commonMarkIndent(line)
+	if indent >= 4 || rest == "" {
+		return marker, false
+	}
+
+	width := 1
+
+	switch rest[0] {
+	case '-', '*', '+':
+		marker.delimiter = rest[0]
+	default:
+		n := 0
+		for n < len(rest) && n < 9 && rest[n] >= '0' && rest[n] <= '9' {
+			n++
+		}
+
+		if n == 0 || n >= len(rest) || (rest[n] != '.' && rest[n] != ')') {
+			return marker, false
+		}
+
+		marker.ordered = true
+		marker.delimiter = rest[n]
+		marker.start, _ = strconv.Atoi(rest[:n])
+		width = n + 1
+	}
+
+	after := rest[width:]
+	if after != "" && after[0] != ' ' && after[0] != '\t' {
+		return marker, false
+	}
+
+	spaces, content := commonMarkIndent(after)
+
+	switch {
+	case content == "":
+		spaces = 1
+	case spaces > 4:
+		// The content is an indented code block.
+		spaces = 1
+		content = commonMarkStripIndent(after, 1)
+	}
+
+	marker.indent = indent + width + spaces
+	marker.content = content
+
+	return marker, true
+}
+
+// commonMarkInterrupts reports whether line starts a block which ends a
+// paragraph.
+func commonMarkInterrupts(line string) bool {
+	if _, ok := commonMarkATXHeading(line); ok {
+		return true
+	}
+
+	if _, _, ok := commonMarkFence(line); ok {
+		return true
+	}
+
+	if commonMarkIsThematicBreak(line) {
+		return true
+	}
+
+	if indent, rest := commonMarkIndent(line); indent < 4 && strings.HasPrefix(rest, ">") {
+		return true
+	}
+
+	marker, ok := commonMarkListItem(line)
+
+	return ok && marker.content != "" && (!marker.ordered || marker.start == 1)
+}
+
+// commonMarkLabel normalizes a link label for matching.
+func commonMarkLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+func commonMarkUnescape(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && commonMarkIsASCIIPunct(s[i+1]) {
+			i++
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return html.UnescapeString(b.String())
+}
+
+func commonMarkIsASCIIPunct(ch byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", ch) >= 0
+}
+
+// commonMarkIsURI reports whether s starts with a URI scheme, as required
+// for autolinks.
+func commonMarkIsURI(s string) bool {
+	colon := strings.IndexByte(s, ':')
+	if colon < 2 || colon > 32 {
+		return false
+	}
+
+	for i := 0; i < colon; i++ {
+		ch := s[i]
+
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z':
+		case i > 0 && (ch >= '0' && ch <= '9' || ch == '+' || ch == '.' || ch == '-'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func commonMarkIsEmail(s string) bool {
+	at := strings.IndexByte(s, '@')
+	return at > 0 && at < len(s)-1 && strings.Count(s, "@") == 1
+}
+
+func commonMarkIsAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != ""
+}
@@ -0,0 +1,166 @@
+package tgbotapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommonMark(t *testing.T) {
+	markdown := "# Release *2.0*\n" +
+		"\n" +
+		"We are **happy** to announce\n" +
+		"the [new version](https://example.com/v2 \"Title\").\n" +
+		"\n" +
+		"- Faster `send`\n" +
+		"- Lists:\n" +
+		"  1. nested\n" +
+		"  2. items\n" +
+		"\n" +
+		"> Quoted ~~text~~\n" +
+		"continues\n" +
+		"\n" +
+		"```go\n" +
+		"fmt.Println(\"*hi*\")\n" +
+		"```\n" +
+		"\n" +
+		"***\n" +
+		"See [docs][] and ![logo](/logo.png) \\*not emphasis\\*.\n" +
+		"\n" +
+		"[docs]: https://example.com/docs\n"
+
+	b := ParseCommonMark(markdown)
+
+	text := "Release 2.0\n" +
+		"\n" +
+		"We are happy to announce the new version.\n" +
+		"\n" +
+		"• Faster send\n" +
+		"• Lists:\n" +
+		"  1. nested\n" +
+		"  2. items\n" +
+		"\n" +
+		"Quoted text continues\n" +
+		"\n" +
+		"fmt.Println(\"*hi*\")\n" +
+		"\n" +
+		"———\n" +
+		"\n" +
+		"See docs and logo *not emphasis*."
+
+	if b.Text() != text {
+		t.Fatalf("unexpected text %q", b.Text())
+	}
+
+	expected := []MessageEntity{
+		{Type: "bold", Offset: 0, Length: 11},
+		{Type: "italic", Offset: 8, Length: 3},
+		{Type: "bold", Offset: 20, Length: 5},
+		{Type: "text_link", Offset: 42, Length: 11, URL: "https://example.com/v2"},
+		{Type: "code", Offset: 65, Length: 4},
+		{Type: "blockquote", Offset: 103, Length: 21},
+		{Type: "strikethrough", Offset: 110, Length: 4},
+		{Type: "pre", Offset: 126, Length: 19, Language: "go"},
+		{Type: "text_link", Offset: 156, Length: 4, URL: "https://example.com/docs"},
+	}
+
+	if entities := b.Entities(); !reflect.DeepEqual(entities, expected) {
+		t.Errorf("unexpected entities %+v", entities)
+	}
+}
+
+func TestParseCommonMarkInline(t *testing.T) {
+	tests := []struct {
+		markdown string
+		text     string
+		entities []MessageEntity
+	}{
+		{
+			markdown: "***both*** and *a **b** c*",
+			text:     "both and a b c",
+			entities: []MessageEntity{
+				{Type: "italic", Offset: 0, Length: 4},
+				{Type: "bold", Offset: 0, Length: 4},
+				{Type: "italic", Offset: 9, Length: 5},
+				{Type: "bold", Offset: 11, Length: 1},
+			},
+		},
+		{
+			markdown: "snake_case_name and _under_",
+			text:     "snake_case_name and under",
+			entities: []MessageEntity{
+				{Type: "italic", Offset: 20, Length: 5},
+			},
+		},
+		{
+			markdown: "2 * 3 * 4 and **unclosed",
+			text:     "2 * 3 * 4 and **unclosed",
+		},
+		{
+			markdown: "`` a`b `` &amp; <https://example.com>",
+			text:     "a`b & https://example.com",
+			entities: []MessageEntity{
+				{Type: "code", Offset: 0, Length: 3},
+				{Type: "text_link", Offset: 6, Length: 19, URL: "https://example.com"},
+			},
+		},
+		{
+			markdown: "line  \nbreak\\\nand soft\nwrap",
+			text:     "line\nbreak\nand soft wrap",
+		},
+		{
+			markdown: "[relative](/path) [*a*](tg://user?id=1)",
+			text:     "relative a",
+			entities: []MessageEntity{
+				{Type: "text_link", Offset: 9, Length: 1, URL: "tg://user?id=1"},
+				{Type: "italic", Offset: 9, Length: 1},
+			},
+		},
+		{
+			markdown: "Title\n===\n\n    indented\n    code",
+			text:     "Title\n\nindented\ncode",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 0, Length: 5},
+				{Type: "pre", Offset: 7, Length: 13},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		b := ParseCommonMark(test.markdown)
+
+		if b.Text() != test.text {
+			t.Errorf("%q: expected text %q, got %q", test.markdown, test.text, b.Text())
+		}
+
+		if entities := b.Entities(); !reflect.DeepEqual(entities, test.entities) {
+			t.Errorf("%q: unexpected entities %+v", test.markdown, entities)
+		}
+	}
+}
+
+func TestCommonMarkToMarkdownV2(t *testing.T) {
+	tests := []struct {
+		markdown string
+		expected string
+	}{
+		{"## Hello *world*!\n\n1. one.\n2. two", "*Hello _world_\\!*\n\n1\\. one\\.\n2\\. two"},
+		{"0000\n* >0", "0000\n\n• \n>0"},
+		{"# `0`", "*`0`*"},
+		{"> a\n>\n> b\n\nc", ">a\n>\n>b\n\nc"},
+		{"> **hello**", ">*hello*"},
+		{"> [a](http://x)", ">[a](http://x)"},
+		{"> # Title", ">*Title*"},
+		{"text\n> _quoted_ line\n> **more**", "text\n\n>_quoted_ line *more*"},
+	}
+
+	for _, test := range tests {
+		md := CommonMarkToMarkdownV2(test.markdown)
+		if md != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.markdown, test.expected, md)
+		}
+
+		if errs := ValidateFormatting(ModeMarkdownV2, md); len(errs) != 0 {
+			t.Errorf("%q: invalid MarkdownV2 %q: %v", test.markdown, md, errs)
+		}
+	}
+}
@@ -92,8 +92,31 @@ const (
 	// UpdateTypeChatMember is when the bot must be an administrator in the chat and must explicitly specify
 	// this update in the list of allowed_updates to receive these updates.
 	UpdateTypeChatMember = "chat_member"
+
+	// UpdateTypeChatJoinRequest is a request to join the chat has been sent. The bot must have the
+	// can_invite_users administrator right in the chat to receive these updates.
+	UpdateTypeChatJoinRequest = "chat_join_request"
 )
 
+// AllUpdateTypes contains every update type, in the order of the fields of
+// Update.
+var AllUpdateTypes = []string{
+	UpdateTypeMessage,
+	UpdateTypeEditedMessage,
+	UpdateTypeChannelPost,
+	UpdateTypeEditedChannelPost,
+	UpdateTypeInlineQuery,
+	UpdateTypeChosenInlineResult,
+	UpdateTypeCallbackQuery,
+	UpdateTypeShippingQuery,
+	UpdateTypePreCheckoutQuery,
+	UpdateTypePoll,
+	UpdateTypePollAnswer,
+	UpdateTypeMyChatMember,
+	UpdateTypeChatMember,
+	UpdateTypeChatJoinRequest,
+}
+
 // Library errors
 const (
 	ErrBadURL = "bad or empty url"
@@ -0,0 +1,138 @@
+package tgbotapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultDeduplicationWindow is how long an UpdateDeduplicator remembers
+// update IDs by default.
+const DefaultDeduplicationWindow = 10 * time.Minute
+
+// SeenUpdateStore keeps track of update IDs which have already been seen.
+//
+// Implementations must be safe for concurrent use. Backing it with a shared
+// store allows multiple replicas behind a webhook to deduplicate updates.
+type SeenUpdateStore interface {
+	// MarkSeen records an update ID as seen for the duration of ttl. It
+	// returns true if the ID had already been recorded and not yet expired.
+	MarkSeen(updateID int, ttl time.Duration) (bool, error)
+}
+
+// MemorySeenUpdateStore is a SeenUpdateStore which keeps update IDs in
+// memory.
+type MemorySeenUpdateStore struct {
+	mu        sync.Mutex
+	seen      map[int]time.Time
+	lastPrune time.Time
+}
+
+// NewMemorySeenUpdateStore creates a new MemorySeenUpdateStore.
+func NewMemorySeenUpdateStore() *MemorySeenUpdateStore {
+	return &MemorySeenUpdateStore{
+		seen: make(map[int]time.Time),
+	}
+}
+
+// MarkSeen records an update ID as seen for the duration of ttl.
+func (s *MemorySeenUpdateStore) MarkSeen(updateID int, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	// Expired IDs are only removed every so often to keep MarkSeen cheap.
+	if now.Sub(s.lastPrune) >= ttl {
+		for id, expires := range s.seen {
+			if !now.Before(expires) {
+				delete(s.seen, id)
+			}
+		}
+
+		s.lastPrune = now
+	}
+
+	expires, ok := s.seen[updateID]
+	s.seen[updateID] = now.Add(ttl)
+
+	return ok && now.Before(expires), nil
+}
+
+// UpdateDeduplicator drops updates with an UpdateID that has already been
+// seen within Window.
+//
+// Telegram redelivers webhook updates after timeouts or failed responses, so
+// the same update may arrive more than once.
+type UpdateDeduplicator struct {
+	Store  SeenUpdateStore
+	Window time.Duration
+}
+
+// NewUpdateDeduplicator creates a new UpdateDeduplicator which keeps seen
+// update IDs in memory.
+//
+// window is how long update IDs are remembered for.
+func NewUpdateDeduplicator(window time.Duration) *UpdateDeduplicator {
+	return &UpdateDeduplicator{
+		Store:  NewMemorySeenUpdateStore(),
+		Window: window,
+	}
+}
+
+// IsDuplicate records the update and returns true if it was already seen.
+//
+// If the store fails, the error is logged and the update is not treated as a
+// duplicate, so that no updates are lost.
+func (d *UpdateDeduplicator) IsDuplicate(update Update) bool {
+	window := d.Window
+	if window == 0 {
+		window = DefaultDeduplicationWindow
+	}
+
+	seen, err := d.Store.MarkSeen(update.UpdateID, window)
+	if err != nil {
+		log.Printf("Failed to check update %d for duplicates: %s", update.UpdateID, err)
+		return false
+	}
+
+	return seen
+}
+
+// Filter returns a channel with the updates from updates which are not
+// duplicates. It is closed once updates is closed.
+//
+// It is intended for use with GetUpdatesChan or ListenForWebhook.
+func (d *UpdateDeduplicator) Filter(updates UpdatesChannel) UpdatesChannel {
+	ch := make(chan Update, cap(updates))
+
+	go func() {
+		defer close(ch)
+
+		for update := range updates {
+			if !d.IsDuplicate(update) {
+				ch <- update
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Wrap returns a HandlerFunc which only calls next for updates which are not
+// duplicates.
+//
+// It is intended for use with WebhookHandler.
+func (d *UpdateDeduplicator) Wrap(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		if d.IsDuplicate(update) {
+			if bot.Debug {
+				log.Printf("Dropping duplicate update %d", update.UpdateID)
+			}
+
+			return nil, nil
+		}
+
+		return next(ctx, bot, update)
+	}
+}
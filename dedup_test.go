@@ -0,0 +1,72 @@
+package tgbotapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemorySeenUpdateStore(t *testing.T) {
+	store := NewMemorySeenUpdateStore()
+
+	if seen, _ := store.MarkSeen(1, time.Minute); seen {
+		t.Error("new update ID should not be seen")
+	}
+
+	if seen, _ := store.MarkSeen(1, time.Minute); !seen {
+		t.Error("repeated update ID should be seen")
+	}
+
+	if seen, _ := store.MarkSeen(2, time.Minute); seen {
+		t.Error("different update ID should not be seen")
+	}
+}
+
+func TestMemorySeenUpdateStoreExpires(t *testing.T) {
+	store := NewMemorySeenUpdateStore()
+
+	store.MarkSeen(1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	if seen, _ := store.MarkSeen(1, time.Millisecond); seen {
+		t.Error("expired update ID should not be seen")
+	}
+}
+
+func TestUpdateDeduplicatorFilter(t *testing.T) {
+	d := NewUpdateDeduplicator(time.Minute)
+
+	in := make(chan Update, 4)
+	in <- Update{UpdateID: 1}
+	in <- Update{UpdateID: 2}
+	in <- Update{UpdateID: 1}
+	in <- Update{UpdateID: 3}
+	close(in)
+
+	var ids []int
+	for update := range d.Filter(in) {
+		ids = append(ids, update.UpdateID)
+	}
+
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Errorf("unexpected updates: %v", ids)
+	}
+}
+
+func TestUpdateDeduplicatorWrap(t *testing.T) {
+	d := NewUpdateDeduplicator(time.Minute)
+
+	calls := 0
+	handler := d.Wrap(func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		calls++
+		return nil, nil
+	})
+
+	bot := newTestBot(&testClient{})
+	handler(context.Background(), bot, Update{UpdateID: 1})
+	handler(context.Background(), bot, Update{UpdateID: 1})
+
+	if calls != 1 {
+		t.Errorf("expected handler to be called once, got %d", calls)
+	}
+}
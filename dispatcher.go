@@ -0,0 +1,344 @@
+package tgbotapi
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Filter reports whether an update should be handled by a handler.
+type Filter func(update Update) bool
+
+// UpdateTypeFilter matches updates of any of the given update types, such as
+// UpdateTypeMessage.
+func UpdateTypeFilter(updateTypes ...string) Filter {
+	return func(update Update) bool {
+		updateType := update.Type()
+
+		for _, t := range updateTypes {
+			if t == updateType {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// CommandFilter matches messages containing any of the given commands,
+// without the leading slash.
+func CommandFilter(commands ...string) Filter {
+	return func(update Update) bool {
+		if update.Message == nil {
+			return false
+		}
+
+		command := update.Message.Command()
+		if command == "" {
+			return false
+		}
+
+		for _, c := range commands {
+			if c == command {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// CallbackPrefixFilter matches callback queries with data starting with
+// prefix.
+func CallbackPrefixFilter(prefix string) Filter {
+	return func(update Update) bool {
+		return update.CallbackQuery != nil && strings.HasPrefix(update.CallbackQuery.Data, prefix)
+	}
+}
+
+// RegexpFilter matches messages with text matching re.
+func RegexpFilter(re *regexp.Regexp) Filter {
+	return func(update Update) bool {
+		return update.Message != nil && re.MatchString(update.Message.Text)
+	}
+}
+
+// ChatTypeFilter matches updates from chats of any of the given types, such
+// as "private" or "supergroup".
+func ChatTypeFilter(chatTypes ...string) Filter {
+	return func(update Update) bool {
+		chat := update.FromChat()
+		if chat == nil {
+			return false
+		}
+
+		for _, chatType := range chatTypes {
+			if chat.Type == chatType {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// AllFilters matches updates matched by every one of filters.
+func AllFilters(filters ...Filter) Filter {
+	return func(update Update) bool {
+		for _, filter := range filters {
+			if !filter(update) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// AnyFilter matches updates matched by at least one of filters.
+func AnyFilter(filters ...Filter) Filter {
+	return func(update Update) bool {
+		for _, filter := range filters {
+			if filter(update) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// chatUpdateTypes are the update types Update.FromChat returns a chat for.
+var chatUpdateTypes = []string{
+	UpdateTypeMessage,
+	UpdateTypeEditedMessage,
+	UpdateTypeChannelPost,
+	UpdateTypeEditedChannelPost,
+	UpdateTypeCallbackQuery,
+}
+
+type route struct {
+	filter      Filter
+	handler     HandlerFunc
+	updateTypes []string
+}
+
+// Dispatcher routes updates to handlers.
+//
+// Handlers are tried in the order they were registered and only the first
+// one with a matching filter is called. If none match, the fallback handler
+// is called.
+type Dispatcher struct {
+	mu          sync.RWMutex
+	routes      []route
+	fallback    HandlerFunc
+	middlewares []Middleware
+}
+
+// NewDispatcher creates a new Dispatcher without any handlers.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// Handle registers a handler for updates matching filter.
+//
+// updateTypes are the update types filter can match, which are used to
+// determine the allowed updates. If none are given, the handler is assumed
+// to need every update type.
+func (d *Dispatcher) Handle(filter Filter, handler HandlerFunc, updateTypes ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.routes = append(d.routes, route{
+		filter:      filter,
+		handler:     handler,
+		updateTypes: updateTypes,
+	})
+}
+
+// HandleUpdateType registers a handler for updates of a type, such as
+// UpdateTypeMessage.
+func (d *Dispatcher) HandleUpdateType(updateType string, handler HandlerFunc) {
+	d.Handle(UpdateTypeFilter(updateType), handler, updateType)
+}
+
+// HandleCommand registers a handler for messages with a command, given
+// without the leading slash.
+func (d *Dispatcher) HandleCommand(command string, handler HandlerFunc) {
+	d.Handle(CommandFilter(command), handler, UpdateTypeMessage)
+}
+
+// HandleCallbackPrefix registers a handler for callback queries with data
+// starting with prefix.
+func (d *Dispatcher) HandleCallbackPrefix(prefix string, handler HandlerFunc) {
+	d.Handle(CallbackPrefixFilter(prefix), handler, UpdateTypeCallbackQuery)
+}
+
+// HandleCallbackCodec registers a handler for callback queries with data in
+// the namespace of codec.
+func (d *Dispatcher) HandleCallbackCodec(codec CallbackCodec, handler HandlerFunc) {
+	d.Handle(codec.Filter(), handler, UpdateTypeCallbackQuery)
+}
+
+// HandleCallbackPayloads registers a handler for callback queries for
+// buttons created by payloads.
+func (d *Dispatcher) HandleCallbackPayloads(payloads *CallbackPayloads, handler CallbackPayloadHandlerFunc) {
+	d.Handle(payloads.Filter(), payloads.Handler(handler), UpdateTypeCallbackQuery)
+}
+
+// HandleRegexp registers a handler for messages with text matching re.
+func (d *Dispatcher) HandleRegexp(re *regexp.Regexp, handler HandlerFunc) {
+	d.Handle(RegexpFilter(re), handler, UpdateTypeMessage)
+}
+
+// HandleChatType registers a handler for updates from chats of a type, such
+// as "private".
+func (d *Dispatcher) HandleChatType(chatType string, handler HandlerFunc) {
+	d.Handle(ChatTypeFilter(chatType), handler, chatUpdateTypes...)
+}
+
+// Fallback registers a handler for updates not matched by any other handler.
+func (d *Dispatcher) Fallback(handler HandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.fallback = handler
+}
+
+// Use adds middlewares run around every update, including updates no
+// handler matched. The first middleware added is the outermost.
+func (d *Dispatcher) Use(middlewares ...Middleware) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.middlewares = append(d.middlewares, middlewares...)
+}
+
+// UpdateTypes returns the update types the registered handlers need, in the
+// order of AllUpdateTypes. A fallback handler needs every update type.
+func (d *Dispatcher) UpdateTypes() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.fallback != nil {
+		return AllowedUpdates(AllUpdateTypes...)
+	}
+
+	var updateTypes []string
+	for _, r := range d.routes {
+		if len(r.updateTypes) == 0 {
+			return AllowedUpdates(AllUpdateTypes...)
+		}
+
+		updateTypes = append(updateTypes, r.updateTypes...)
+	}
+
+	return AllowedUpdates(updateTypes...)
+}
+
+// CheckAllowedUpdates logs a warning for every update type the registered
+// handlers need which is not in allowed, and returns those update types.
+//
+// allowed is the value of UpdateConfig.AllowedUpdates or
+// WebhookConfig.AllowedUpdates.
+func (d *Dispatcher) CheckAllowedUpdates(allowed []string) []string {
+	missing := MissingUpdateTypes(allowed, d.UpdateTypes())
+
+	for _, updateType := range missing {
+		log.Printf("Handlers need %s updates, which are not in allowed updates", updateType)
+	}
+
+	return missing
+}
+
+// Dispatch calls the handler for an update. It is a HandlerFunc, so a
+// Dispatcher can be used with a WebhookHandler.
+func (d *Dispatcher) Dispatch(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+	d.mu.RLock()
+	middlewares := d.middlewares
+	d.mu.RUnlock()
+
+	return Chain(d.dispatch, middlewares...)(ctx, bot, update)
+}
+
+// dispatch calls the matching handler for an update without middlewares.
+func (d *Dispatcher) dispatch(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+	handler := d.match(update)
+	if handler == nil {
+		return nil, nil
+	}
+
+	return handler(ctx, bot, update)
+}
+
+// match returns the handler for an update, or nil if there is none.
+func (d *Dispatcher) match(update Update) HandlerFunc {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, r := range d.routes {
+		if r.filter(update) {
+			return r.handler
+		}
+	}
+
+	return d.fallback
+}
+
+// Run dispatches every update from updates, one at a time, until the channel
+// is closed. Chattables returned by handlers are sent using Request.
+func (d *Dispatcher) Run(bot *BotAPI, updates UpdatesChannel) {
+	for update := range updates {
+		serveUpdate(context.Background(), bot, d.Dispatch, update)
+	}
+}
+
+// AllowedUpdates returns the minimal allowed updates list for receiving the
+// given update types, without duplicates and in the order of AllUpdateTypes.
+//
+// It is intended for UpdateConfig.AllowedUpdates and
+// WebhookConfig.AllowedUpdates.
+func AllowedUpdates(updateTypes ...string) []string {
+	needed := make(map[string]bool)
+	for _, updateType := range updateTypes {
+		needed[updateType] = true
+	}
+
+	allowed := []string{}
+	for _, updateType := range AllUpdateTypes {
+		if needed[updateType] {
+			allowed = append(allowed, updateType)
+		}
+	}
+
+	return allowed
+}
+
+// MissingUpdateTypes returns the update types in needed which would not be
+// received with the allowed updates list allowed.
+//
+// An empty allowed list receives every update type except
+// UpdateTypeChatMember, as Telegram does.
+func MissingUpdateTypes(allowed []string, needed []string) []string {
+	received := make(map[string]bool)
+
+	if len(allowed) == 0 {
+		for _, updateType := range AllUpdateTypes {
+			received[updateType] = updateType != UpdateTypeChatMember
+		}
+	}
+
+	for _, updateType := range allowed {
+		received[updateType] = true
+	}
+
+	var missing []string
+	for _, updateType := range needed {
+		if !received[updateType] {
+			missing = append(missing, updateType)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,187 @@
+package tgbotapi
+
+import (
+	"context"
+	"regexp"
+	"testing"
+)
+
+func newTestCommandUpdate(chatType, text string) Update {
+	message := &Message{
+		MessageID: 1,
+		Chat:      &Chat{ID: 3, Type: chatType},
+		From:      &User{ID: 4},
+		Text:      text,
+	}
+
+	if len(text) > 0 && text[0] == '/' {
+		length := len(text)
+		for i, c := range text {
+			if c == ' ' {
+				length = i
+				break
+			}
+		}
+
+		message.Entities = []MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+
+	return Update{UpdateID: 1, Message: message}
+}
+
+func newTestCallbackUpdate(data string) Update {
+	return Update{UpdateID: 2, CallbackQuery: &CallbackQuery{
+		ID:   "5",
+		From: &User{ID: 4},
+		Data: data,
+		Message: &Message{
+			MessageID: 6,
+			Chat:      &Chat{ID: 3, Type: "private"},
+		},
+	}}
+}
+
+// namedHandler returns a handler which records its name in called.
+func namedHandler(name string, called *[]string) HandlerFunc {
+	return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		*called = append(*called, name)
+		return nil, nil
+	}
+}
+
+func TestDispatcherRouting(t *testing.T) {
+	var called []string
+
+	d := NewDispatcher()
+	d.HandleCommand("start", namedHandler("start", &called))
+	d.HandleCallbackPrefix("page:", namedHandler("page", &called))
+	d.HandleRegexp(regexp.MustCompile(`^hello`), namedHandler("hello", &called))
+	d.HandleChatType("group", namedHandler("group", &called))
+	d.HandleUpdateType(UpdateTypeMessage, namedHandler("message", &called))
+	d.Fallback(namedHandler("fallback", &called))
+
+	bot := newTestBot(&testClient{})
+	ctx := context.Background()
+
+	updates := []Update{
+		newTestCommandUpdate("private", "/start now"),
+		newTestCallbackUpdate("page:2"),
+		newTestCommandUpdate("private", "hello there"),
+		newTestCommandUpdate("group", "hi all"),
+		newTestCommandUpdate("private", "hi"),
+		newTestCallbackUpdate("other"),
+		{UpdateID: 3, InlineQuery: &InlineQuery{ID: "7"}},
+	}
+
+	for _, update := range updates {
+		d.Dispatch(ctx, bot, update)
+	}
+
+	expected := []string{"start", "page", "hello", "group", "message", "fallback", "fallback"}
+	if len(called) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, called)
+	}
+
+	for i := range expected {
+		if called[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, called)
+		}
+	}
+}
+
+func TestDispatcherOrder(t *testing.T) {
+	var called []string
+
+	d := NewDispatcher()
+	d.HandleUpdateType(UpdateTypeMessage, namedHandler("message", &called))
+	d.HandleCommand("start", namedHandler("start", &called))
+
+	d.Dispatch(context.Background(), newTestBot(&testClient{}), newTestCommandUpdate("private", "/start"))
+
+	if len(called) != 1 || called[0] != "message" {
+		t.Errorf("expected first registered handler to win, got %v", called)
+	}
+}
+
+func TestDispatcherRun(t *testing.T) {
+	client := &testClient{}
+
+	d := NewDispatcher()
+	d.HandleCommand("echo", func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		return NewMessage(update.Message.Chat.ID, update.Message.CommandArguments()), nil
+	})
+
+	updates := make(chan Update, 1)
+	updates <- newTestCommandUpdate("private", "/echo hi")
+	close(updates)
+
+	d.Run(newTestBot(client), updates)
+
+	requests := client.Requests()
+	if len(requests) != 1 || requests[0].endpoint != "sendMessage" || requests[0].values.Get("text") != "hi" {
+		t.Errorf("expected echoed message, got %v", requests)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	private := newTestCommandUpdate("private", "/start")
+
+	if !AllFilters(CommandFilter("start"), ChatTypeFilter("private"))(private) {
+		t.Error("expected all filters to match")
+	}
+
+	if AllFilters(CommandFilter("start"), ChatTypeFilter("group"))(private) {
+		t.Error("expected all filters not to match")
+	}
+
+	if !AnyFilter(CommandFilter("help"), ChatTypeFilter("private"))(private) {
+		t.Error("expected any filter to match")
+	}
+
+	inline := Update{CallbackQuery: &CallbackQuery{ID: "1", InlineMessageID: "2"}}
+	if ChatTypeFilter("private")(inline) {
+		t.Error("expected callback without message not to match chat type")
+	}
+}
+
+func TestDispatcherUpdateTypes(t *testing.T) {
+	handler := namedHandler("", &[]string{})
+
+	d := NewDispatcher()
+	d.HandleCallbackPrefix("page:", handler)
+	d.HandleCommand("start", handler)
+	d.HandleUpdateType(UpdateTypeChatMember, handler)
+	d.HandleRegexp(regexp.MustCompile(`.`), handler)
+
+	updateTypes := d.UpdateTypes()
+	expected := []string{UpdateTypeMessage, UpdateTypeCallbackQuery, UpdateTypeChatMember}
+
+	if len(updateTypes) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, updateTypes)
+	}
+
+	for i := range expected {
+		if updateTypes[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, updateTypes)
+		}
+	}
+
+	missing := d.CheckAllowedUpdates(nil)
+	if len(missing) != 1 || missing[0] != UpdateTypeChatMember {
+		t.Errorf("expected chat_member to be missing by default, got %v", missing)
+	}
+
+	missing = d.CheckAllowedUpdates([]string{UpdateTypeMessage})
+	if len(missing) != 2 {
+		t.Errorf("expected two missing update types, got %v", missing)
+	}
+
+	if missing = d.CheckAllowedUpdates(updateTypes); len(missing) != 0 {
+		t.Errorf("expected no missing update types, got %v", missing)
+	}
+
+	d.Fallback(handler)
+	if len(d.UpdateTypes()) != len(AllUpdateTypes) {
+		t.Error("expected fallback to need every update type")
+	}
+}
@@ -0,0 +1,482 @@
+package tgbotapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Constant values for FormField kinds
+const (
+	FormFieldText     = "text"
+	FormFieldInt      = "int"
+	FormFieldDate     = "date"
+	FormFieldChoice   = "choice"
+	FormFieldContact  = "contact"
+	FormFieldLocation = "location"
+)
+
+// Default values for Form
+const (
+	DefaultFormDateLayout    = "2006-01-02"
+	DefaultFormCancelCommand = "cancel"
+	DefaultFormBackCommand   = "back"
+	DefaultFormCancelText    = "Cancelled."
+)
+
+// FormField is a single question asked by a Form.
+type FormField struct {
+	// Name identifies the answer in the FormResult.
+	Name string
+	// Kind is the type of answer expected, such as FormFieldText.
+	Kind string
+	// Prompt is the question sent to the user.
+	Prompt string
+	// Choices are the answers offered on a keyboard for FormFieldChoice.
+	//
+	// optional
+	Choices []string
+	// DateLayout is the layout answers for FormFieldDate are parsed with.
+	// Defaults to DefaultFormDateLayout.
+	//
+	// optional
+	DateLayout string
+	// ButtonText is the text of the button requesting a contact or location.
+	//
+	// optional
+	ButtonText string
+	// ErrorText is sent before asking the question again when the answer is
+	// not of the expected kind. Defaults to a text describing the expected
+	// answer.
+	//
+	// optional
+	ErrorText string
+	// Validate is called with the parsed answer. If it returns an error, the
+	// error is shown to the user and the question is asked again.
+	//
+	// optional
+	Validate func(value string) error
+}
+
+// NewFormTextField creates a new field asking for any text.
+func NewFormTextField(name, prompt string) FormField {
+	return FormField{
+		Name:   name,
+		Kind:   FormFieldText,
+		Prompt: prompt,
+	}
+}
+
+// NewFormIntField creates a new field asking for an integer.
+func NewFormIntField(name, prompt string) FormField {
+	return FormField{
+		Name:   name,
+		Kind:   FormFieldInt,
+		Prompt: prompt,
+	}
+}
+
+// NewFormDateField creates a new field asking for a date in
+// DefaultFormDateLayout.
+func NewFormDateField(name, prompt string) FormField {
+	return FormField{
+		Name:       name,
+		Kind:       FormFieldDate,
+		Prompt:     prompt,
+		DateLayout: DefaultFormDateLayout,
+	}
+}
+
+// NewFormChoiceField creates a new field asking to choose one of choices
+// from a keyboard.
+func NewFormChoiceField(name, prompt string, choices ...string) FormField {
+	return FormField{
+		Name:    name,
+		Kind:    FormFieldChoice,
+		Prompt:  prompt,
+		Choices: choices,
+	}
+}
+
+// NewFormContactField creates a new field asking the user to share their
+// contact.
+func NewFormContactField(name, prompt string) FormField {
+	return FormField{
+		Name:       name,
+		Kind:       FormFieldContact,
+		Prompt:     prompt,
+		ButtonText: "Share contact",
+	}
+}
+
+// NewFormLocationField creates a new field asking the user to share their
+// location.
+func NewFormLocationField(name, prompt string) FormField {
+	return FormField{
+		Name:       name,
+		Kind:       FormFieldLocation,
+		Prompt:     prompt,
+		ButtonText: "Share location",
+	}
+}
+
+// parse returns the answer to the field from a message. If the answer is
+// not valid, it returns the text to send before asking again instead.
+func (f FormField) parse(message *Message) (value, retry string, err error) {
+	switch f.Kind {
+	case FormFieldContact:
+		if message.Contact == nil {
+			return "", f.errorText(), nil
+		}
+
+		data, err := json.Marshal(message.Contact)
+		if err != nil {
+			return "", "", err
+		}
+
+		value = string(data)
+	case FormFieldLocation:
+		if message.Location == nil {
+			return "", f.errorText(), nil
+		}
+
+		data, err := json.Marshal(message.Location)
+		if err != nil {
+			return "", "", err
+		}
+
+		value = string(data)
+	default:
+		value = strings.TrimSpace(message.Text)
+		if value == "" {
+			return "", f.errorText(), nil
+		}
+	}
+
+	valid := true
+
+	switch f.Kind {
+	case FormFieldInt:
+		_, err := strconv.Atoi(value)
+		valid = err == nil
+	case FormFieldDate:
+		_, err := time.Parse(f.dateLayout(), value)
+		valid = err == nil
+	case FormFieldChoice:
+		valid = false
+		for _, choice := range f.Choices {
+			if choice == value {
+				valid = true
+				break
+			}
+		}
+	}
+
+	if !valid {
+		return "", f.errorText(), nil
+	}
+
+	if f.Validate != nil {
+		if err := f.Validate(value); err != nil {
+			return "", err.Error(), nil
+		}
+	}
+
+	return value, "", nil
+}
+
+// errorText returns the text sent when an answer is not of the expected
+// kind.
+func (f FormField) errorText() string {
+	if f.ErrorText != "" {
+		return f.ErrorText
+	}
+
+	switch f.Kind {
+	case FormFieldInt:
+		return "Please answer with a whole number."
+	case FormFieldDate:
+		return "Please answer with a date like " + f.dateLayout() + "."
+	case FormFieldChoice:
+		return "Please choose one of the options."
+	case FormFieldContact:
+		return "Please share a contact."
+	case FormFieldLocation:
+		return "Please share a location."
+	}
+
+	return "Please answer with text."
+}
+
+func (f FormField) dateLayout() string {
+	if f.DateLayout == "" {
+		return DefaultFormDateLayout
+	}
+
+	return f.DateLayout
+}
+
+// replyMarkup returns the markup sent with the prompt for the field.
+func (f FormField) replyMarkup() interface{} {
+	switch f.Kind {
+	case FormFieldChoice:
+		var rows [][]KeyboardButton
+		for _, choice := range f.Choices {
+			rows = append(rows, NewKeyboardButtonRow(NewKeyboardButton(choice)))
+		}
+
+		markup := NewOneTimeReplyKeyboard(rows...)
+		markup.Selective = true
+		return markup
+	case FormFieldContact:
+		markup := NewOneTimeReplyKeyboard(NewKeyboardButtonRow(NewKeyboardButtonContact(f.ButtonText)))
+		markup.Selective = true
+		return markup
+	case FormFieldLocation:
+		markup := NewOneTimeReplyKeyboard(NewKeyboardButtonRow(NewKeyboardButtonLocation(f.ButtonText)))
+		markup.Selective = true
+		return markup
+	default:
+		return ForceReply{
+			ForceReply: true,
+			Selective:  true,
+		}
+	}
+}
+
+// FormCompleteFunc is called with the answers once a form is complete.
+type FormCompleteFunc func(ctx context.Context, bot *BotAPI, update Update, result FormResult) (Chattable, error)
+
+// Form asks the user a series of questions, one at a time, and collects the
+// validated answers.
+//
+// Users may go back to the previous question with BackCommand or stop
+// answering with CancelCommand. Each question is a state of a StateMachine,
+// so the form must be registered with one before it is started.
+type Form struct {
+	// Name identifies the form. It must be unique within a StateMachine.
+	Name       string
+	Fields     []FormField
+	OnComplete FormCompleteFunc
+	// CancelCommand stops the form. Defaults to DefaultFormCancelCommand.
+	CancelCommand string
+	// BackCommand asks the previous question again. Defaults to
+	// DefaultFormBackCommand.
+	BackCommand string
+	// CancelText is sent when the form is cancelled. Defaults to
+	// DefaultFormCancelText.
+	CancelText string
+
+	machine *StateMachine
+}
+
+// NewForm creates a new Form asking for fields, calling onComplete once
+// every field has been answered.
+func NewForm(name string, onComplete FormCompleteFunc, fields ...FormField) *Form {
+	return &Form{
+		Name:          name,
+		Fields:        fields,
+		OnComplete:    onComplete,
+		CancelCommand: DefaultFormCancelCommand,
+		BackCommand:   DefaultFormBackCommand,
+		CancelText:    DefaultFormCancelText,
+	}
+}
+
+// Register adds a state for every field to a StateMachine.
+func (f *Form) Register(machine *StateMachine) {
+	f.machine = machine
+
+	for i := range f.Fields {
+		machine.Handle(f.state(i), f.handler(i))
+	}
+}
+
+// Start begins the form for the sender of an update and returns the first
+// question.
+func (f *Form) Start(update Update) (Chattable, error) {
+	if f.machine == nil {
+		return nil, errors.New("form is not registered with a state machine")
+	}
+
+	if len(f.Fields) == 0 {
+		return nil, errors.New("form has no fields")
+	}
+
+	if err := f.machine.Start(update, f.state(0)); err != nil {
+		return nil, err
+	}
+
+	return f.prompt(update, 0, ""), nil
+}
+
+func (f *Form) state(index int) string {
+	return fmt.Sprintf("form:%s:%d", f.Name, index)
+}
+
+// prompt returns the question for a field, preceded by problem if it is
+// not empty.
+func (f *Form) prompt(update Update, index int, problem string) Chattable {
+	field := f.Fields[index]
+
+	text := field.Prompt
+	if problem != "" {
+		text = problem + "\n\n" + text
+	}
+
+	msg := NewMessage(update.FromChat().ID, text)
+	msg.ReplyMarkup = field.replyMarkup()
+
+	if update.Message != nil {
+		msg.ReplyToMessageID = update.Message.MessageID
+	}
+
+	return msg
+}
+
+func (f *Form) handler(index int) StateHandlerFunc {
+	return func(ctx context.Context, bot *BotAPI, update Update, conversation *Conversation) (Chattable, error) {
+		if update.Message == nil {
+			return nil, nil
+		}
+
+		switch command := update.Message.Command(); {
+		case command != "" && command == f.CancelCommand:
+			conversation.Finish()
+
+			msg := NewMessage(update.Message.Chat.ID, f.CancelText)
+			msg.ReplyMarkup = NewRemoveKeyboard(true)
+			return msg, nil
+		case command != "" && command == f.BackCommand:
+			previous := index
+			if previous > 0 {
+				previous--
+				conversation.Transition(f.state(previous))
+			}
+
+			return f.prompt(update, previous, ""), nil
+		}
+
+		value, retry, err := f.Fields[index].parse(update.Message)
+		if err != nil {
+			return nil, err
+		}
+
+		if retry != "" {
+			return f.prompt(update, index, retry), nil
+		}
+
+		conversation.Set(f.Fields[index].Name, value)
+
+		if index+1 < len(f.Fields) {
+			conversation.Transition(f.state(index + 1))
+			return f.prompt(update, index+1, ""), nil
+		}
+
+		conversation.Finish()
+
+		if f.OnComplete == nil {
+			return nil, nil
+		}
+
+		return f.OnComplete(ctx, bot, update, FormResult{
+			Values: conversation.Data,
+			form:   f,
+		})
+	}
+}
+
+// FormResult contains the answers to a completed Form.
+type FormResult struct {
+	// Values are the answers keyed by field name. Contacts and locations are
+	// JSON encoded.
+	Values map[string]string
+
+	form *Form
+}
+
+// Decode stores the answers in the struct pointed to by v.
+//
+// Struct fields are matched to form fields with a `form:"name"` tag. They
+// may be strings, integers, time.Time for dates, and Contact or Location,
+// or pointers to them. Unexported fields are skipped.
+func (r FormResult) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("form result must be decoded into a pointer to a struct")
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		name := rt.Field(i).Tag.Get("form")
+		if name == "" || !rv.Field(i).CanSet() {
+			continue
+		}
+
+		value, ok := r.Values[name]
+		if !ok {
+			continue
+		}
+
+		if err := r.decodeValue(rv.Field(i), name, value); err != nil {
+			return fmt.Errorf("form field %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (r FormResult) decodeValue(field reflect.Value, name, value string) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+
+		field = field.Elem()
+	}
+
+	switch field.Interface().(type) {
+	case time.Time:
+		layout := DefaultFormDateLayout
+		if r.form != nil {
+			for _, f := range r.form.Fields {
+				if f.Name == name {
+					layout = f.dateLayout()
+				}
+			}
+		}
+
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return err
+		}
+
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case Contact, Location:
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
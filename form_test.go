@@ -0,0 +1,143 @@
+package tgbotapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSignup struct {
+	Name    string    `form:"name"`
+	Age     int       `form:"age"`
+	Date    time.Time `form:"date"`
+	Color   string    `form:"color"`
+	Contact *Contact  `form:"contact"`
+}
+
+func TestForm(t *testing.T) {
+	var signup testSignup
+
+	form := NewForm("signup", func(ctx context.Context, bot *BotAPI, update Update, result FormResult) (Chattable, error) {
+		if err := result.Decode(&signup); err != nil {
+			return nil, err
+		}
+
+		return NewMessage(update.Message.Chat.ID, "done"), nil
+	},
+		NewFormTextField("name", "Name?"),
+		NewFormIntField("age", "Age?"),
+		NewFormDateField("date", "Date?"),
+		NewFormChoiceField("color", "Color?", "red", "blue"),
+		NewFormContactField("contact", "Contact?"),
+	)
+
+	machine := NewStateMachine(nil)
+	form.Register(machine)
+
+	d := NewDispatcher()
+	d.Use(machine.Middleware())
+	d.HandleCommand("signup", func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		return form.Start(update)
+	})
+
+	bot := newTestBot(&testClient{})
+
+	send := func(update Update) MessageConfig {
+		t.Helper()
+
+		c, err := d.Dispatch(context.Background(), bot, update)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg, ok := c.(MessageConfig)
+		if !ok {
+			t.Fatalf("expected message, got %#v", c)
+		}
+
+		return msg
+	}
+
+	steps := []struct {
+		text     string
+		expected string
+	}{
+		{"/signup", "Name?"},
+		{"bob", "Age?"},
+		{"old", "whole number"},
+		{"30", "Date?"},
+		{"/back", "Age?"},
+		{"31", "Date?"},
+		{"2024-02-30", "date like"},
+		{"2024-02-03", "Color?"},
+		{"green", "choose one"},
+		{"red", "Contact?"},
+		{"555", "share a contact"},
+	}
+
+	for _, step := range steps {
+		msg := send(newTestCommandUpdate("private", step.text))
+		if !strings.Contains(msg.Text, step.expected) {
+			t.Fatalf("after %q expected %q, got %q", step.text, step.expected, msg.Text)
+		}
+	}
+
+	if _, ok := send(newTestCommandUpdate("private", "red")).ReplyMarkup.(ReplyKeyboardMarkup); !ok {
+		t.Error("expected contact prompt to have a keyboard")
+	}
+
+	contact := newTestCommandUpdate("private", "")
+	contact.Message.Contact = &Contact{PhoneNumber: "555", FirstName: "Bob"}
+
+	if msg := send(contact); msg.Text != "done" {
+		t.Fatalf("expected form to complete, got %q", msg.Text)
+	}
+
+	if signup.Name != "bob" || signup.Age != 31 || signup.Color != "red" ||
+		!signup.Date.Equal(time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)) ||
+		signup.Contact == nil || signup.Contact.PhoneNumber != "555" {
+		t.Errorf("unexpected result %+v", signup)
+	}
+
+	send(newTestCommandUpdate("private", "/signup"))
+	if msg := send(newTestCommandUpdate("private", "/cancel")); msg.Text != DefaultFormCancelText {
+		t.Errorf("expected form to be cancelled, got %q", msg.Text)
+	}
+
+	if state, _ := machine.Current(newTestCommandUpdate("private", "")); state != nil {
+		t.Errorf("expected no conversation after cancel, got %v", state)
+	}
+}
+
+func TestFormFieldErrorText(t *testing.T) {
+	field := NewFormIntField("age", "Age?")
+	field.ErrorText = "Numbers only"
+
+	message := &Message{Text: "old"}
+
+	if _, retry, err := field.parse(message); err != nil || retry != "Numbers only" {
+		t.Errorf("expected custom error text, got %q, %v", retry, err)
+	}
+
+	message.Text = "31"
+	if value, retry, err := field.parse(message); err != nil || retry != "" || value != "31" {
+		t.Errorf("expected valid answer, got %q, %q, %v", value, retry, err)
+	}
+}
+
+func TestFormResultDecodeUnexported(t *testing.T) {
+	var v struct {
+		Name string `form:"name"`
+		age  int    `form:"age"`
+	}
+
+	result := FormResult{Values: map[string]string{"name": "bob", "age": "31"}}
+	if err := result.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Name != "bob" || v.age != 0 {
+		t.Errorf("unexpected result %+v", v)
+	}
+}
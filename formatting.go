@@ -0,0 +1,565 @@
+package tgbotapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormattingError describes formatting in text which Telegram would fail to
+// parse.
+type FormattingError struct {
+	// Offset is the byte offset of the error in the text, as used in errors
+	// returned by Telegram.
+	Offset int
+	// Length is the number of bytes causing the error.
+	Length int
+	Reason string
+}
+
+// Error message string.
+func (e FormattingError) Error() string {
+	return fmt.Sprintf("can't parse entities: %s at byte offset %d", e.Reason, e.Offset)
+}
+
+// ValidateFormatting checks that text can be parsed with parseMode and
+// returns the errors found, ordered by offset.
+//
+// ModeHTML text is checked for supported tags, their attributes and nesting,
+// and for unescaped & characters. ModeMarkdownV2 text is checked for
+// unescaped reserved characters, unbalanced entities and nested links. Text
+// in other parse modes is not checked.
+func ValidateFormatting(parseMode, text string) []FormattingError {
+	var errs []FormattingError
+
+	switch parseMode {
+	case ModeHTML:
+		errs = validateHTML(text)
+	case ModeMarkdownV2:
+		errs = validateMarkdownV2(text)
+	default:
+		return nil
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Offset < errs[j].Offset
+	})
+
+	return errs
+}
+
+// RepairFormatting escapes the characters causing errors in text, so it can
+// be parsed with parseMode while keeping all valid formatting.
+//
+// If text still can't be parsed after escaping, it is escaped entirely.
+func RepairFormatting(parseMode, text string) string {
+	var escape func(string) string
+
+	switch parseMode {
+	case ModeHTML:
+		escape = func(s string) string {
+			return EscapeText(ModeHTML, s)
+		}
+	case ModeMarkdownV2:
+		escape = func(s string) string {
+			var b strings.Builder
+			for _, r := range s {
+				if strings.ContainsRune(markdownV2Reserved+"\\", r) {
+					b.WriteByte('\\')
+				}
+
+				b.WriteRune(r)
+			}
+
+			return b.String()
+		}
+	default:
+		return text
+	}
+
+	// Escaping a character can change how the text after it is parsed, so
+	// the text is checked again after every pass.
+	for pass := 0; pass < 16; pass++ {
+		errs := ValidateFormatting(parseMode, text)
+		if len(errs) == 0 {
+			return text
+		}
+
+		var (
+			b    strings.Builder
+			last int
+		)
+
+		for _, err := range errs {
+			if err.Offset < last {
+				continue
+			}
+
+			b.WriteString(text[last:err.Offset])
+			b.WriteString(escape(text[err.Offset : err.Offset+err.Length]))
+			last = err.Offset + err.Length
+		}
+
+		b.WriteString(text[last:])
+		text = b.String()
+	}
+
+	if len(ValidateFormatting(parseMode, text)) == 0 {
+		return text
+	}
+
+	return EscapeText(parseMode, text)
+}
+
+// checkFormatting validates the text and captions of request params against
+// their parse mode and returns the first error found.
+func checkFormatting(params Params) error {
+	fields := []struct{ parseMode, text string }{
+		{"parse_mode", "text"},
+		{"parse_mode", "caption"},
+		{"explanation_parse_mode", "explanation"},
+	}
+
+	for _, field := range fields {
+		if errs := ValidateFormatting(params[field.parseMode], params[field.text]); len(errs) > 0 {
+			return errs[0]
+		}
+	}
+
+	// Media groups and edited media have their captions in the encoded
+	// media, which is a single item or a list of them.
+	media := params["media"]
+	if strings.HasPrefix(media, "{") {
+		media = "[" + media + "]"
+	}
+
+	var items []struct {
+		Caption   string `json:"caption"`
+		ParseMode string `json:"parse_mode"`
+	}
+
+	if err := json.Unmarshal([]byte(media), &items); err != nil {
+		return nil
+	}
+
+	for _, item := range items {
+		if errs := ValidateFormatting(item.ParseMode, item.Caption); len(errs) > 0 {
+			return errs[0]
+		}
+	}
+
+	return nil
+}
+
+// htmlTags are the tags supported in ModeHTML text.
+var htmlTags = map[string]bool{
+	"b": true, "strong": true,
+	"i": true, "em": true,
+	"u": true, "ins": true,
+	"s": true, "strike": true, "del": true,
+	"span": true, "tg-spoiler": true,
+	"a": true, "tg-emoji": true,
+	"code": true, "pre": true,
+	"blockquote": true,
+}
+
+type htmlTag struct {
+	name          string
+	offset, width int
+}
+
+func validateHTML(text string) []FormattingError {
+	var (
+		errs  []FormattingError
+		stack []htmlTag
+	)
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '&':
+			n := htmlEntityLength(text[i:])
+			if n == 0 {
+				errs = append(errs, FormattingError{i, 1, "unsupported HTML entity"})
+				n = 1
+			}
+
+			i += n
+		case '<':
+			end := htmlTagEnd(text[i:])
+			if end < 0 {
+				errs = append(errs, FormattingError{i, 1, "unclosed start tag"})
+				i++
+				continue
+			}
+
+			tag := htmlTag{offset: i, width: end + 1}
+			content := text[i+1 : i+end]
+
+			if strings.HasPrefix(content, "/") {
+				tag.name = strings.ToLower(strings.TrimSpace(content[1:]))
+
+				if len(stack) == 0 || stack[len(stack)-1].name != tag.name {
+					errs = append(errs, FormattingError{i, tag.width, fmt.Sprintf("unexpected end tag %q", tag.name)})
+				} else {
+					stack = stack[:len(stack)-1]
+				}
+			} else {
+				name, attrs, ok := parseHTMLTag(content)
+				tag.name = name
+
+				if !ok {
+					errs = append(errs, FormattingError{i, tag.width, "invalid start tag"})
+				} else if reason := checkHTMLTag(name, attrs, stack); reason != "" {
+					errs = append(errs, FormattingError{i, tag.width, reason})
+				} else {
+					stack = append(stack, tag)
+				}
+			}
+
+			i += tag.width
+		default:
+			i++
+		}
+	}
+
+	for _, tag := range stack {
+		errs = append(errs, FormattingError{tag.offset, tag.width, fmt.Sprintf("can't find end tag corresponding to start tag %q", tag.name)})
+	}
+
+	return errs
+}
+
+// checkHTMLTag returns why a start tag is invalid inside the open tags in
+// stack, or an empty string if it is valid.
+func checkHTMLTag(name string, attrs map[string]string, stack []htmlTag) string {
+	if !htmlTags[name] {
+		return fmt.Sprintf("unsupported start tag %q", name)
+	}
+
+	switch name {
+	case "span":
+		if attrs["class"] != "tg-spoiler" {
+			return `span tag must have class "tg-spoiler"`
+		}
+	case "tg-emoji":
+		if attrs["emoji-id"] == "" {
+			return "tg-emoji tag must have an emoji-id"
+		}
+	}
+
+	for i, open := range stack {
+		switch {
+		case open.name == "code":
+			return "tags can't be nested in code"
+		case open.name == "pre" && (name != "code" || i != len(stack)-1):
+			return "only a code tag can be nested in pre"
+		case open.name == "a" && name == "a":
+			return "links can't be nested"
+		case open.name == "blockquote" && name == "blockquote":
+			return "blockquotes can't be nested"
+		}
+	}
+
+	return ""
+}
+
+// htmlTagEnd returns the index of the > ending the tag at the start of s,
+// skipping quoted attribute values, or -1 if there is none.
+func htmlTagEnd(s string) int {
+	var quote byte
+
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '<':
+			return -1
+		case s[i] == '>':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// parseHTMLTag parses the name and attributes of a start tag without the <
+// and >.
+func parseHTMLTag(s string) (string, map[string]string, bool) {
+	isName := func(ch byte) bool {
+		return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_'
+	}
+
+	n := 0
+	for n < len(s) && isName(s[n]) {
+		n++
+	}
+
+	if n == 0 {
+		return "", nil, false
+	}
+
+	name := strings.ToLower(s[:n])
+	attrs := make(map[string]string)
+
+	s = s[n:]
+	for {
+		trimmed := strings.TrimLeft(s, " \t\n")
+		if trimmed == "" {
+			return name, attrs, true
+		}
+
+		if len(trimmed) == len(s) {
+			return name, nil, false
+		}
+
+		s = trimmed
+
+		n := 0
+		for n < len(s) && isName(s[n]) {
+			n++
+		}
+
+		if n == 0 {
+			return name, nil, false
+		}
+
+		attr := strings.ToLower(s[:n])
+		s = s[n:]
+
+		if !strings.HasPrefix(s, "=") {
+			attrs[attr] = ""
+			continue
+		}
+
+		s = s[1:]
+
+		var value string
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				return name, nil, false
+			}
+
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexAny(s, " \t\n")
+			if end < 0 {
+				end = len(s)
+			}
+
+			value, s = s[:end], s[end:]
+		}
+
+		attrs[attr] = value
+	}
+}
+
+// htmlEntityLength returns the length of the HTML entity at the start of s,
+// or 0 if it is not one supported by Telegram.
+func htmlEntityLength(s string) int {
+	semicolon := strings.IndexByte(s, ';')
+	if semicolon < 2 || semicolon > 10 {
+		return 0
+	}
+
+	name := s[1:semicolon]
+
+	switch name {
+	case "lt", "gt", "amp", "quot":
+		return semicolon + 1
+	}
+
+	if name[0] != '#' {
+		return 0
+	}
+
+	digits, base := name[1:], 10
+	if strings.HasPrefix(digits, "x") || strings.HasPrefix(digits, "X") {
+		digits, base = digits[1:], 16
+	}
+
+	if _, err := strconv.ParseUint(digits, base, 32); digits == "" || err != nil {
+		return 0
+	}
+
+	return semicolon + 1
+}
+
+// markdownV2Reserved are the characters which must be escaped in
+// ModeMarkdownV2 text outside of entity markers.
+const markdownV2Reserved = "_*[]()~`>#+-=|{}.!"
+
+type markdownV2Marker struct {
+	token  string
+	offset int
+}
+
+func validateMarkdownV2(text string) []FormattingError {
+	var (
+		errs  []FormattingError
+		stack []markdownV2Marker
+	)
+
+	reserved := func(i int) {
+		errs = append(errs, FormattingError{i, 1, fmt.Sprintf("character '%c' is reserved and must be escaped with the preceding '\\'", text[i])})
+	}
+
+	// toggle opens an entity, or closes it if it is the innermost open one.
+	toggle := func(token string, i int) {
+		for j := len(stack) - 1; j >= 0; j-- {
+			if stack[j].token != token {
+				continue
+			}
+
+			if j != len(stack)-1 {
+				errs = append(errs, FormattingError{i, len(token), fmt.Sprintf("entity %q overlaps with entity %q", token, stack[len(stack)-1].token)})
+				return
+			}
+
+			stack = stack[:j]
+			return
+		}
+
+		stack = append(stack, markdownV2Marker{token, i})
+	}
+
+	for i := 0; i < len(text); {
+		lineStart := i == 0 || text[i-1] == '\n'
+
+		switch ch := text[i]; {
+		case ch == '\\':
+			if i+1 == len(text) {
+				errs = append(errs, FormattingError{i, 1, "text must not end with '\\'"})
+				i++
+				continue
+			}
+
+			_, size := utf8.DecodeRuneInString(text[i+1:])
+			i += 1 + size
+		case strings.HasPrefix(text[i:], "```"):
+			end, codeErrs := markdownV2CodeEnd(text, i+3, "```")
+			errs = append(errs, codeErrs...)
+
+			if end < 0 {
+				errs = append(errs, FormattingError{i, 3, "can't find end of pre entity"})
+				i += 3
+				continue
+			}
+
+			i = end + 3
+		case ch == '`':
+			end, codeErrs := markdownV2CodeEnd(text, i+1, "`")
+			errs = append(errs, codeErrs...)
+
+			if end < 0 {
+				errs = append(errs, FormattingError{i, 1, "can't find end of code entity"})
+				i++
+				continue
+			}
+
+			i = end + 1
+		case lineStart && strings.HasPrefix(text[i:], "**>"):
+			i += 3
+		case lineStart && ch == '>':
+			i++
+		case strings.HasPrefix(text[i:], "||"):
+			toggle("||", i)
+			i += 2
+		case strings.HasPrefix(text[i:], "__"):
+			toggle("__", i)
+			i += 2
+		case ch == '*' || ch == '_' || ch == '~':
+			toggle(text[i:i+1], i)
+			i++
+		case ch == '[':
+			for _, open := range stack {
+				if open.token == "[" {
+					errs = append(errs, FormattingError{i, 1, "links can't be nested"})
+					break
+				}
+			}
+
+			stack = append(stack, markdownV2Marker{"[", i})
+			i++
+		case strings.HasPrefix(text[i:], "!["):
+			stack = append(stack, markdownV2Marker{"![", i})
+			i += 2
+		case ch == ']':
+			if len(stack) == 0 || (stack[len(stack)-1].token != "[" && stack[len(stack)-1].token != "![") {
+				reserved(i)
+				i++
+				continue
+			}
+
+			open := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			end := -1
+			if strings.HasPrefix(text[i+1:], "(") {
+				end = markdownV2URLEnd(text, i+2)
+			}
+
+			if end < 0 {
+				errs = append(errs, FormattingError{open.offset, len(open.token), "can't find URL of link"})
+				reserved(i)
+				i++
+				continue
+			}
+
+			i = end + 1
+		case strings.IndexByte(markdownV2Reserved, ch) >= 0:
+			reserved(i)
+			i++
+		default:
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+		}
+	}
+
+	for _, marker := range stack {
+		errs = append(errs, FormattingError{marker.offset, len(marker.token), fmt.Sprintf("can't find end of entity %q", marker.token)})
+	}
+
+	return errs
+}
+
+// markdownV2CodeEnd returns the index of the marker ending code or pre text
+// starting at text[start], and errors for unescaped backticks within it.
+func markdownV2CodeEnd(text string, start int, marker string) (int, []FormattingError) {
+	var errs []FormattingError
+
+	for i := start; i < len(text); i++ {
+		switch {
+		case text[i] == '\\':
+			i++
+		case strings.HasPrefix(text[i:], marker):
+			return i, errs
+		case text[i] == '`':
+			errs = append(errs, FormattingError{i, 1, "character '`' must be escaped in code"})
+		}
+	}
+
+	return -1, nil
+}
+
+// markdownV2URLEnd returns the index of the ) ending the URL of a link
+// starting at text[start], or -1 if there is none.
+func markdownV2URLEnd(text string, start int) int {
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case ')':
+			return i
+		}
+	}
+
+	return -1
+}
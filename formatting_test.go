@@ -0,0 +1,158 @@
+package tgbotapi
+
+import "testing"
+
+func TestValidateFormattingHTML(t *testing.T) {
+	tests := []struct {
+		text    string
+		offsets []int
+	}{
+		{`<b>bold <i>italic</i></b> &lt;3 &#128512; &#x1F600;`, nil},
+		{`<a href="https://example.com/?a=1&amp;b=>">link</a>`, nil},
+		{`<span class="tg-spoiler">x</span><tg-emoji emoji-id="1">👍</tg-emoji>`, nil},
+		{`<pre><code class="language-go">x</code></pre>`, nil},
+		{`<b>bold <i>overlap</b></i>`, []int{0, 18}},
+		{`1 < 2 & 3 > 2`, []int{2}},
+		{`1 < 2 & 3`, []int{2, 6}},
+		{`<br> <span>x</span>`, []int{0, 5, 12}},
+		{`<code><b>x</b></code>`, []int{6, 10}},
+		{`<b>unclosed`, []int{0}},
+	}
+
+	for _, test := range tests {
+		errs := ValidateFormatting(ModeHTML, test.text)
+
+		var offsets []int
+		for _, err := range errs {
+			offsets = append(offsets, err.Offset)
+		}
+
+		if len(offsets) != len(test.offsets) {
+			t.Errorf("%q: expected errors at %v, got %v", test.text, test.offsets, errs)
+			continue
+		}
+
+		for i := range offsets {
+			if offsets[i] != test.offsets[i] {
+				t.Errorf("%q: expected errors at %v, got %v", test.text, test.offsets, errs)
+				break
+			}
+		}
+	}
+}
+
+func TestValidateFormattingMarkdownV2(t *testing.T) {
+	tests := []struct {
+		text    string
+		offsets []int
+	}{
+		{"*bold _italic_* __under__ ~s~ ||sp|| \\. \\\\", nil},
+		{"[link](https://example.com/\\)) ![👍](tg://emoji?id=1)", nil},
+		{"`a\\`b` ```go\nx := \\`\\\\\n```", nil},
+		{">quote\n>lines\n**>expandable", nil},
+		{"Hello world!", []int{11}},
+		{"*bold", []int{0}},
+		{"1. a-b", []int{1, 4}},
+		{"*a _b* c_", []int{0, 5}},
+		{"[text] (x)", []int{0, 5, 7, 9}},
+		{"`code", []int{0}},
+		{"ends with \\", []int{10}},
+		{"[a [b](x)](y)", []int{3}},
+	}
+
+	for _, test := range tests {
+		errs := ValidateFormatting(ModeMarkdownV2, test.text)
+
+		if len(errs) != len(test.offsets) {
+			t.Errorf("%q: expected errors at %v, got %v", test.text, test.offsets, errs)
+			continue
+		}
+
+		for i, err := range errs {
+			if err.Offset != test.offsets[i] {
+				t.Errorf("%q: expected errors at %v, got %v", test.text, test.offsets, errs)
+				break
+			}
+		}
+	}
+}
+
+func TestRepairFormatting(t *testing.T) {
+	tests := []struct {
+		parseMode string
+		text      string
+		expected  string
+	}{
+		{ModeHTML, "<b>1 < 2 & 3</b>", "<b>1 &lt; 2 &amp; 3</b>"},
+		{ModeHTML, "<b>bold <br>", "&lt;b&gt;bold &lt;br&gt;"},
+		{ModeMarkdownV2, "*Hello* world!", "*Hello* world\\!"},
+		{ModeMarkdownV2, "*bold _x", "\\*bold \\_x"},
+		{ModeMarkdownV2, "`unclosed *code*", "\\`unclosed *code*"},
+		{ModeMarkdown, "*a", "*a"},
+	}
+
+	for _, test := range tests {
+		repaired := RepairFormatting(test.parseMode, test.text)
+		if repaired != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.text, test.expected, repaired)
+		}
+
+		if errs := ValidateFormatting(test.parseMode, repaired); len(errs) != 0 {
+			t.Errorf("%q: repaired text is invalid: %v", test.text, errs)
+		}
+	}
+}
+
+func TestBotCheckFormatting(t *testing.T) {
+	client := &testClient{results: map[string]string{
+		"sendMessage": `{"message_id": 1}`,
+	}}
+
+	bot := newTestBot(client)
+	bot.CheckFormatting = true
+
+	msg := NewMessage(3, "<b>unclosed")
+	msg.ParseMode = ModeHTML
+
+	_, err := bot.Send(msg)
+	if _, ok := err.(FormattingError); !ok {
+		t.Fatalf("expected FormattingError, got %v", err)
+	}
+
+	if len(client.Requests()) != 0 {
+		t.Error("invalid message should not be sent")
+	}
+
+	msg.Text = "<b>closed</b>"
+	if _, err := bot.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	photo := NewPhoto(3, FileID("photo"))
+	photo.Caption = "Hello!"
+	photo.ParseMode = ModeMarkdownV2
+
+	if _, err := bot.Send(photo); err == nil {
+		t.Error("expected invalid caption to be rejected")
+	}
+
+	valid := NewInputMediaPhoto(FileID("photo"))
+	invalid := NewInputMediaPhoto(FileID("photo"))
+	invalid.Caption = "<b>unclosed"
+	invalid.ParseMode = ModeHTML
+
+	if _, err := bot.Request(NewMediaGroup(3, []interface{}{valid, invalid})); err == nil {
+		t.Error("expected invalid caption in media group to be rejected")
+	}
+
+	if _, err := bot.Request(EditMessageMediaConfig{
+		BaseEdit: BaseEdit{ChatID: 3, MessageID: 1},
+		Media:    invalid,
+	}); err == nil {
+		t.Error("expected invalid caption of edited media to be rejected")
+	}
+
+	if requests := client.Requests(); len(requests) != 1 {
+		t.Errorf("expected only the valid message to be sent, got %v", requests)
+	}
+}
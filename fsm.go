@@ -0,0 +1,468 @@
+package tgbotapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StateKey identifies a conversation with a user in a chat.
+type StateKey struct {
+	ChatID int64
+	UserID int64
+}
+
+// String returns the key as "chatID:userID".
+func (k StateKey) String() string {
+	return fmt.Sprintf("%d:%d", k.ChatID, k.UserID)
+}
+
+// StateKeyFor returns the StateKey for an update. It returns false if the
+// update does not have both a chat and a sender.
+func StateKeyFor(update Update) (StateKey, bool) {
+	chat := update.FromChat()
+	user := update.SentFrom()
+
+	if chat == nil || user == nil {
+		return StateKey{}, false
+	}
+
+	return StateKey{ChatID: chat.ID, UserID: user.ID}, true
+}
+
+// State is the stored state of a conversation.
+type State struct {
+	// Name is the name of the current state.
+	Name string `json:"name"`
+	// Data contains values collected during the conversation.
+	//
+	// optional
+	Data map[string]string `json:"data,omitempty"`
+	// UpdatedAt is when the state was last changed.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StateStorage stores the state of conversations.
+//
+// Implementations must be safe for concurrent use.
+type StateStorage interface {
+	// GetState returns the state for a key, or nil if there is none.
+	GetState(key StateKey) (*State, error)
+	// SetState stores the state for a key.
+	SetState(key StateKey, state State) error
+	// DeleteState removes the state for a key.
+	DeleteState(key StateKey) error
+}
+
+// MemoryStateStorage is a StateStorage which keeps states in memory.
+type MemoryStateStorage struct {
+	mu     sync.Mutex
+	states map[StateKey]State
+}
+
+// NewMemoryStateStorage creates a new MemoryStateStorage.
+func NewMemoryStateStorage() *MemoryStateStorage {
+	return &MemoryStateStorage{
+		states: make(map[StateKey]State),
+	}
+}
+
+// GetState returns the state for a key, or nil if there is none.
+func (s *MemoryStateStorage) GetState(key StateKey) (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[key]
+	if !ok {
+		return nil, nil
+	}
+
+	state.Data = copyStateData(state.Data)
+
+	return &state, nil
+}
+
+// SetState stores the state for a key.
+func (s *MemoryStateStorage) SetState(key StateKey, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.Data = copyStateData(state.Data)
+	s.states[key] = state
+
+	return nil
+}
+
+// DeleteState removes the state for a key.
+func (s *MemoryStateStorage) DeleteState(key StateKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, key)
+
+	return nil
+}
+
+// FileStateStorage is a StateStorage which keeps states in a local JSON file,
+// so conversations survive restarts.
+//
+// The file is read and rewritten on every change, so it is only suitable for
+// bots with a modest number of active conversations.
+type FileStateStorage struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileStateStorage creates a new FileStateStorage storing states at path.
+// The file is created when the first state is stored.
+func NewFileStateStorage(path string) *FileStateStorage {
+	return &FileStateStorage{
+		Path: path,
+	}
+}
+
+// GetState returns the state for a key, or nil if there is none.
+func (s *FileStateStorage) GetState(key StateKey) (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	state, ok := states[key.String()]
+	if !ok {
+		return nil, nil
+	}
+
+	return &state, nil
+}
+
+// SetState stores the state for a key.
+func (s *FileStateStorage) SetState(key StateKey, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	states[key.String()] = state
+
+	return s.save(states)
+}
+
+// DeleteState removes the state for a key.
+func (s *FileStateStorage) DeleteState(key StateKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := states[key.String()]; !ok {
+		return nil
+	}
+
+	delete(states, key.String())
+
+	return s.save(states)
+}
+
+func (s *FileStateStorage) load() (map[string]State, error) {
+	states := make(map[string]State)
+
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// save writes states to a temporary file and renames it, so a crash never
+// leaves a partially written file behind.
+func (s *FileStateStorage) save(states map[string]State) error {
+	data, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+func copyStateData(data map[string]string) map[string]string {
+	if data == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(data))
+	for k, v := range data {
+		c[k] = v
+	}
+
+	return c
+}
+
+// Conversation is the state of a conversation given to a StateHandlerFunc.
+//
+// Changes made by the handler are stored once it returns without an error.
+type Conversation struct {
+	Key   StateKey
+	State string
+	Data  map[string]string
+
+	next     string
+	finished bool
+}
+
+// Get returns a value collected during the conversation.
+func (c *Conversation) Get(key string) string {
+	return c.Data[key]
+}
+
+// Set stores a value for the rest of the conversation.
+func (c *Conversation) Set(key, value string) {
+	if c.Data == nil {
+		c.Data = make(map[string]string)
+	}
+
+	c.Data[key] = value
+}
+
+// Transition moves the conversation to another state after the handler
+// returns. If neither Transition nor Finish are called, the conversation
+// stays in the current state.
+func (c *Conversation) Transition(state string) {
+	c.next = state
+	c.finished = false
+}
+
+// Finish ends the conversation after the handler returns.
+func (c *Conversation) Finish() {
+	c.finished = true
+}
+
+// StateHandlerFunc handles an update for a conversation in a state.
+type StateHandlerFunc func(ctx context.Context, bot *BotAPI, update Update, conversation *Conversation) (Chattable, error)
+
+// StateMachine routes updates from users in a conversation to the handler for
+// their current state.
+//
+// Conversations are keyed by chat and user. Used as a middleware, the
+// handler for the current state takes priority over other handlers, such as
+// command handlers in a Dispatcher.
+type StateMachine struct {
+	Storage StateStorage
+	// Timeout is how long a conversation may be idle before it is dropped.
+	// Zero means conversations never time out.
+	//
+	// optional
+	Timeout time.Duration
+	// OnTimeout is called for the update which found the conversation timed
+	// out. If it is nil, the update is passed on as if there was no
+	// conversation.
+	//
+	// optional
+	OnTimeout HandlerFunc
+	// CancelCommand is a command, without the leading slash, which ends the
+	// conversation in any state.
+	//
+	// optional
+	CancelCommand string
+	// OnCancel is called when a conversation is ended with CancelCommand.
+	//
+	// optional
+	OnCancel HandlerFunc
+
+	mu       sync.RWMutex
+	handlers map[string]StateHandlerFunc
+	timeouts map[string]time.Duration
+}
+
+// NewStateMachine creates a new StateMachine using storage. If storage is
+// nil, states are kept in memory.
+func NewStateMachine(storage StateStorage) *StateMachine {
+	if storage == nil {
+		storage = NewMemoryStateStorage()
+	}
+
+	return &StateMachine{
+		Storage:  storage,
+		handlers: make(map[string]StateHandlerFunc),
+		timeouts: make(map[string]time.Duration),
+	}
+}
+
+// Handle registers the handler for a state.
+func (m *StateMachine) Handle(state string, handler StateHandlerFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlers[state] = handler
+}
+
+// SetStateTimeout overrides Timeout for conversations in a state.
+func (m *StateMachine) SetStateTimeout(state string, timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.timeouts[state] = timeout
+}
+
+// Start begins a conversation with the sender of an update in its chat, in
+// state. Any existing conversation is replaced.
+func (m *StateMachine) Start(update Update, state string) error {
+	key, ok := StateKeyFor(update)
+	if !ok {
+		return errors.New("update has no chat or sender")
+	}
+
+	return m.Storage.SetState(key, State{Name: state, UpdatedAt: time.Now()})
+}
+
+// Cancel ends the conversation with the sender of an update, if any.
+func (m *StateMachine) Cancel(update Update) error {
+	key, ok := StateKeyFor(update)
+	if !ok {
+		return nil
+	}
+
+	return m.Storage.DeleteState(key)
+}
+
+// Current returns the state of the conversation with the sender of an
+// update, or nil if there is none.
+func (m *StateMachine) Current(update Update) (*State, error) {
+	key, ok := StateKeyFor(update)
+	if !ok {
+		return nil, nil
+	}
+
+	return m.Storage.GetState(key)
+}
+
+// Middleware returns a Middleware which calls the handler for the current
+// state of a conversation instead of the next handler.
+func (m *StateMachine) Middleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+			key, ok := StateKeyFor(update)
+			if !ok {
+				return next(ctx, bot, update)
+			}
+
+			state, err := m.Storage.GetState(key)
+			if err != nil {
+				return nil, err
+			}
+
+			if state == nil {
+				return next(ctx, bot, update)
+			}
+
+			if m.expired(*state) {
+				if err := m.Storage.DeleteState(key); err != nil {
+					return nil, err
+				}
+
+				if m.OnTimeout != nil {
+					return m.OnTimeout(ctx, bot, update)
+				}
+
+				return next(ctx, bot, update)
+			}
+
+			if m.CancelCommand != "" && update.Message != nil && update.Message.Command() == m.CancelCommand {
+				if err := m.Storage.DeleteState(key); err != nil {
+					return nil, err
+				}
+
+				if m.OnCancel != nil {
+					return m.OnCancel(ctx, bot, update)
+				}
+
+				return nil, nil
+			}
+
+			m.mu.RLock()
+			handler := m.handlers[state.Name]
+			m.mu.RUnlock()
+
+			if handler == nil {
+				log.Printf("No handler for conversation state %q", state.Name)
+				return next(ctx, bot, update)
+			}
+
+			conversation := &Conversation{
+				Key:   key,
+				State: state.Name,
+				Data:  state.Data,
+				next:  state.Name,
+			}
+
+			c, err := handler(ctx, bot, update, conversation)
+			if err != nil {
+				return c, err
+			}
+
+			if conversation.finished {
+				err = m.Storage.DeleteState(key)
+			} else {
+				err = m.Storage.SetState(key, State{
+					Name:      conversation.next,
+					Data:      conversation.Data,
+					UpdatedAt: time.Now(),
+				})
+			}
+
+			return c, err
+		}
+	}
+}
+
+// expired reports whether a conversation has been idle for longer than the
+// timeout for its state.
+func (m *StateMachine) expired(state State) bool {
+	m.mu.RLock()
+	timeout, ok := m.timeouts[state.Name]
+	m.mu.RUnlock()
+
+	if !ok {
+		timeout = m.Timeout
+	}
+
+	return timeout > 0 && time.Since(state.UpdatedAt) > timeout
+}
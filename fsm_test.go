@@ -0,0 +1,119 @@
+package tgbotapi
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStateMachine() (*StateMachine, *Dispatcher, *[]string) {
+	var called []string
+
+	m := NewStateMachine(nil)
+	m.CancelCommand = "cancel"
+
+	m.Handle("name", func(ctx context.Context, bot *BotAPI, update Update, conversation *Conversation) (Chattable, error) {
+		called = append(called, "name")
+		conversation.Set("name", update.Message.Text)
+		conversation.Transition("confirm")
+		return nil, nil
+	})
+	m.Handle("confirm", func(ctx context.Context, bot *BotAPI, update Update, conversation *Conversation) (Chattable, error) {
+		called = append(called, "confirm:"+conversation.Get("name"))
+		if update.Message.Text == "yes" {
+			conversation.Finish()
+		}
+		return nil, nil
+	})
+
+	d := NewDispatcher()
+	d.Use(m.Middleware())
+	d.HandleCommand("start", func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		called = append(called, "start")
+		return nil, m.Start(update, "name")
+	})
+	d.Fallback(namedHandler("fallback", &called))
+
+	return m, d, &called
+}
+
+func TestStateMachine(t *testing.T) {
+	m, d, called := newTestStateMachine()
+
+	bot := newTestBot(&testClient{})
+	ctx := context.Background()
+
+	for _, text := range []string{"/start", "bob", "no", "yes", "hi"} {
+		if _, err := d.Dispatch(ctx, bot, newTestCommandUpdate("private", text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{"start", "name", "confirm:bob", "confirm:bob", "fallback"}
+	if len(*called) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, *called)
+	}
+
+	for i := range expected {
+		if (*called)[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, *called)
+		}
+	}
+
+	if state, _ := m.Current(newTestCommandUpdate("private", "")); state != nil {
+		t.Errorf("expected conversation to be finished, got %v", state)
+	}
+}
+
+func TestStateMachineCancelAndTimeout(t *testing.T) {
+	m, d, called := newTestStateMachine()
+
+	bot := newTestBot(&testClient{})
+	ctx := context.Background()
+
+	d.Dispatch(ctx, bot, newTestCommandUpdate("private", "/start"))
+	d.Dispatch(ctx, bot, newTestCommandUpdate("private", "/cancel"))
+
+	if state, _ := m.Current(newTestCommandUpdate("private", "")); state != nil {
+		t.Errorf("expected conversation to be cancelled, got %v", state)
+	}
+
+	m.SetStateTimeout("name", time.Millisecond)
+	d.Dispatch(ctx, bot, newTestCommandUpdate("private", "/start"))
+	time.Sleep(5 * time.Millisecond)
+	d.Dispatch(ctx, bot, newTestCommandUpdate("private", "bob"))
+
+	expected := []string{"start", "start", "fallback"}
+	if len(*called) != len(expected) || (*called)[2] != "fallback" {
+		t.Errorf("expected %v, got %v", expected, *called)
+	}
+}
+
+func TestFileStateStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "states.json")
+	key := StateKey{ChatID: 3, UserID: 4}
+
+	s := NewFileStateStorage(path)
+
+	if state, err := s.GetState(key); err != nil || state != nil {
+		t.Fatalf("expected no state, got %v, %v", state, err)
+	}
+
+	if err := s.SetState(key, State{Name: "name", Data: map[string]string{"a": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := NewFileStateStorage(path).GetState(key)
+	if err != nil || state == nil || state.Name != "name" || state.Data["a"] != "b" {
+		t.Fatalf("expected stored state, got %v, %v", state, err)
+	}
+
+	if err := s.DeleteState(key); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, err := s.GetState(key); err != nil || state != nil {
+		t.Errorf("expected state to be deleted, got %v, %v", state, err)
+	}
+}
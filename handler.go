@@ -0,0 +1,82 @@
+package tgbotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// HandlerFunc handles a single update.
+//
+// It may return a Chattable to answer the update with. Depending on how the
+// handler is run, it is either written as the webhook response or sent with
+// Request. A nil Chattable means there is nothing to send.
+type HandlerFunc func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error)
+
+// serveUpdate runs a handler for an update and sends the returned Chattable
+// using Request. Errors are logged as there is nobody to return them to.
+func serveUpdate(ctx context.Context, bot *BotAPI, handler HandlerFunc, update Update) {
+	c, err := handler(ctx, bot, update)
+	if err != nil {
+		logHandlerError(fmt.Sprintf("update %d", update.UpdateID), err)
+		return
+	}
+
+	sendHandlerResult(bot, update, c)
+}
+
+// sendHandlerResult sends a Chattable returned by a handler using Request.
+func sendHandlerResult(bot *BotAPI, update Update, c Chattable) {
+	if c == nil {
+		return
+	}
+
+	if _, err := bot.Request(c); err != nil {
+		log.Printf("Failed to send response to update %d: %s", update.UpdateID, err)
+	}
+}
+
+// handlerPanic is the error a panic in a handler is turned into. The panic
+// has already been logged with its stack when it is returned.
+type handlerPanic struct {
+	value interface{}
+}
+
+func (p handlerPanic) Error() string {
+	return fmt.Sprintf("handler panicked: %v", p.value)
+}
+
+// callRecovered calls a handler, turning a panic into a handlerPanic error.
+// The panic is logged with its stack, so subject describes what was being
+// handled, such as "update 1".
+func callRecovered(subject string, call func() (Chattable, error)) (c Chattable, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Handler panicked on %s: %v\n%s", subject, r, debug.Stack())
+			c, err = nil, handlerPanic{r}
+		}
+	}()
+
+	return call()
+}
+
+// recoverHandler wraps handler so panics are recovered with callRecovered.
+func recoverHandler(handler HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		return callRecovered(fmt.Sprintf("update %d", update.UpdateID), func() (Chattable, error) {
+			return handler(ctx, bot, update)
+		})
+	}
+}
+
+// logHandlerError logs an error returned by a handler, unless it is a panic
+// which was already logged.
+func logHandlerError(subject string, err error) {
+	var panicked handlerPanic
+	if errors.As(err, &panicked) {
+		return
+	}
+
+	log.Printf("Failed to handle %s: %s", subject, err)
+}
@@ -0,0 +1,135 @@
+package tgbotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Middleware wraps a HandlerFunc to add behavior around it.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain wraps handler with middlewares. The first middleware is the
+// outermost, so it runs first.
+func Chain(handler HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// RecoverMiddleware recovers from panics in handlers and turns them into
+// errors.
+//
+// If adminChatID is not zero, a message describing the panic is sent to that
+// chat.
+func RecoverMiddleware(adminChatID int64) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		recovered := recoverHandler(next)
+
+		return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+			c, err := recovered(ctx, bot, update)
+
+			var panicked handlerPanic
+			if adminChatID != 0 && errors.As(err, &panicked) {
+				msg := NewMessage(adminChatID, fmt.Sprintf("Panic handling update %d: %v", update.UpdateID, panicked.value))
+				if _, sendErr := bot.Request(msg); sendErr != nil {
+					log.Printf("Failed to report panic: %s", sendErr)
+				}
+			}
+
+			return c, err
+		}
+	}
+}
+
+// TimeoutMiddleware cancels the context given to handlers after timeout.
+//
+// If the handler does not return in time, context.DeadlineExceeded is
+// returned and anything the handler returns afterwards is discarded.
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			type result struct {
+				handlerResult
+				panicked interface{}
+			}
+
+			done := make(chan result, 1)
+			go func() {
+				// Panics are passed on, so they can still be recovered by
+				// middleware running in the calling goroutine.
+				defer func() {
+					if r := recover(); r != nil {
+						done <- result{panicked: r}
+					}
+				}()
+
+				c, err := next(ctx, bot, update)
+				done <- result{handlerResult: handlerResult{c, err}}
+			}()
+
+			select {
+			case r := <-done:
+				if r.panicked != nil {
+					panic(r.panicked)
+				}
+
+				return r.chattable, r.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// LoggingMiddleware logs every update with its ID, type, sender, how long it
+// took to handle and any error.
+func LoggingMiddleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+			start := time.Now()
+
+			c, err := next(ctx, bot, update)
+
+			if err != nil {
+				log.Printf("update_id=%d type=%s from=%s duration=%s error=%q",
+					update.UpdateID, update.Type(), update.SentFrom(), time.Since(start), err)
+			} else {
+				log.Printf("update_id=%d type=%s from=%s duration=%s",
+					update.UpdateID, update.Type(), update.SentFrom(), time.Since(start))
+			}
+
+			return c, err
+		}
+	}
+}
+
+// AllowUsersMiddleware only calls handlers for updates sent by one of
+// userIDs. Other updates, including ones without a sender, are ignored.
+func AllowUsersMiddleware(userIDs ...int64) Middleware {
+	allowed := make(map[int64]bool)
+	for _, userID := range userIDs {
+		allowed[userID] = true
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+			user := update.SentFrom()
+			if user == nil || !allowed[user.ID] {
+				if bot.Debug {
+					log.Printf("Ignoring update %d from user not allowed", update.UpdateID)
+				}
+
+				return nil, nil
+			}
+
+			return next(ctx, bot, update)
+		}
+	}
+}
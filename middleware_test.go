@@ -0,0 +1,103 @@
+package tgbotapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	var called []string
+
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+				called = append(called, name)
+				return next(ctx, bot, update)
+			}
+		}
+	}
+
+	d := NewDispatcher()
+	d.Use(record("first"), record("second"))
+	d.HandleCommand("start", namedHandler("start", &called))
+
+	d.Dispatch(context.Background(), newTestBot(&testClient{}), newTestCommandUpdate("private", "/start"))
+
+	expected := []string{"first", "second", "start"}
+	if len(called) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, called)
+	}
+
+	for i := range expected {
+		if called[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, called)
+		}
+	}
+
+	called = nil
+	d.Dispatch(context.Background(), newTestBot(&testClient{}), newTestCallbackUpdate("data"))
+
+	if len(called) != 2 {
+		t.Errorf("expected middlewares to run for unmatched updates, got %v", called)
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	client := &testClient{}
+
+	handler := Chain(func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		panic("test panic")
+	}, RecoverMiddleware(10))
+
+	c, err := handler(context.Background(), newTestBot(client), newTestCommandUpdate("private", "hi"))
+	if c != nil || err == nil {
+		t.Errorf("expected panic to be returned as error, got %v, %v", c, err)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 1 || requests[0].endpoint != "sendMessage" || requests[0].values.Get("chat_id") != "10" {
+		t.Errorf("expected panic report to admin chat, got %v", requests)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handler := Chain(func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return NewMessage(3, "late"), nil
+	}, TimeoutMiddleware(10*time.Millisecond))
+
+	c, err := handler(context.Background(), newTestBot(&testClient{}), newTestCommandUpdate("private", "hi"))
+	if c != nil || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v, %v", c, err)
+	}
+
+	handler = Chain(func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		panic("test panic")
+	}, RecoverMiddleware(0), TimeoutMiddleware(time.Second))
+
+	if _, err := handler(context.Background(), newTestBot(&testClient{}), newTestCommandUpdate("private", "hi")); err == nil {
+		t.Error("expected panic inside timeout to be recovered")
+	}
+}
+
+func TestAllowUsersMiddleware(t *testing.T) {
+	var called []string
+
+	handler := Chain(namedHandler("handler", &called), AllowUsersMiddleware(4))
+	bot := newTestBot(&testClient{})
+
+	handler(context.Background(), bot, newTestCommandUpdate("private", "hi"))
+
+	other := newTestCommandUpdate("private", "hi")
+	other.Message.From = &User{ID: 5}
+	handler(context.Background(), bot, other)
+
+	handler(context.Background(), bot, Update{UpdateID: 3, Poll: &Poll{ID: "1"}})
+
+	if len(called) != 1 {
+		t.Errorf("expected only allowed user to be handled, got %v", called)
+	}
+}
@@ -0,0 +1,242 @@
+package tgbotapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Constant values for WebhookEvent.Type
+const (
+	// WebhookEventCheckFailed is when fetching the webhook info failed.
+	WebhookEventCheckFailed = "check_failed"
+	// WebhookEventPendingUpdates is when the number of pending updates
+	// exceeds the threshold.
+	WebhookEventPendingUpdates = "pending_updates"
+	// WebhookEventDeliveryError is when Telegram reports a new error
+	// delivering updates to the webhook.
+	WebhookEventDeliveryError = "delivery_error"
+	// WebhookEventURLChanged is when the webhook URL no longer matches the
+	// configured webhook.
+	WebhookEventURLChanged = "url_changed"
+	// WebhookEventReregistered is when the webhook was set again after its
+	// URL changed. Err is set if it could not be set.
+	WebhookEventReregistered = "reregistered"
+)
+
+// Default values for WebhookMonitorConfig
+const (
+	DefaultWebhookMonitorInterval = time.Minute
+	DefaultWebhookErrorWindow     = 5 * time.Minute
+)
+
+// WebhookEvent is raised by a WebhookMonitor when it notices a problem.
+type WebhookEvent struct {
+	// Type is one of the WebhookEvent constants.
+	Type string
+	// Info is the webhook info the event was raised for.
+	Info WebhookInfo
+	// Err is the error that occurred, for WebhookEventCheckFailed or a
+	// failed re-registration.
+	Err error
+}
+
+// WebhookMonitorConfig contains information about how a WebhookMonitor
+// checks the webhook.
+type WebhookMonitorConfig struct {
+	// Interval is how often the webhook info is fetched. Defaults to
+	// DefaultWebhookMonitorInterval.
+	Interval time.Duration
+	// PendingUpdatesThreshold is the number of pending updates above which
+	// the webhook is considered unhealthy. Zero disables the check.
+	PendingUpdatesThreshold int
+	// ErrorWindow is how long after a delivery error the webhook is
+	// considered unhealthy. Defaults to DefaultWebhookErrorWindow.
+	ErrorWindow time.Duration
+	// Webhook is set again if the webhook URL no longer matches it.
+	//
+	// optional
+	Webhook *WebhookConfig
+	// OnEvent is called for every event raised.
+	//
+	// optional
+	OnEvent func(event WebhookEvent)
+}
+
+// WebhookStatus is the result of the most recent check of a WebhookMonitor.
+type WebhookStatus struct {
+	Healthy            bool      `json:"healthy"`
+	CheckedAt          time.Time `json:"checked_at"`
+	URL                string    `json:"url"`
+	PendingUpdateCount int       `json:"pending_update_count"`
+	LastErrorDate      int       `json:"last_error_date,omitempty"`
+	LastErrorMessage   string    `json:"last_error_message,omitempty"`
+	Problems           []string  `json:"problems,omitempty"`
+}
+
+// WebhookMonitor periodically fetches the webhook info and raises events when
+// the webhook looks unhealthy.
+//
+// It is a http.Handler reporting the status of the most recent check, for
+// use as a health check endpoint.
+type WebhookMonitor struct {
+	bot    *BotAPI
+	config WebhookMonitorConfig
+
+	mu            sync.Mutex
+	status        WebhookStatus
+	lastErrorDate int
+	stop          chan interface{}
+	stopOnce      sync.Once
+}
+
+// NewWebhookMonitor creates a new WebhookMonitor.
+//
+// Only delivery errors which happen after the monitor is created raise
+// events.
+func NewWebhookMonitor(bot *BotAPI, config WebhookMonitorConfig) *WebhookMonitor {
+	return &WebhookMonitor{
+		bot:           bot,
+		config:        config,
+		lastErrorDate: int(time.Now().Unix()),
+		stop:          make(chan interface{}),
+	}
+}
+
+// Start checks the webhook every interval until Stop is called.
+func (m *WebhookMonitor) Start() {
+	interval := m.config.Interval
+	if interval == 0 {
+		interval = DefaultWebhookMonitorInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			m.Check()
+
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops checking the webhook.
+func (m *WebhookMonitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+}
+
+// Status returns the status of the most recent check.
+func (m *WebhookMonitor) Status() WebhookStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.status
+}
+
+// Check fetches the webhook info once, raises events for any problems found
+// and updates the status.
+func (m *WebhookMonitor) Check() WebhookStatus {
+	var events []WebhookEvent
+
+	status := WebhookStatus{CheckedAt: time.Now()}
+
+	info, err := m.bot.GetWebhookInfo()
+	if err != nil {
+		status.Problems = append(status.Problems, "failed to get webhook info: "+err.Error())
+		events = append(events, WebhookEvent{Type: WebhookEventCheckFailed, Err: err})
+
+		return m.update(status, events, 0)
+	}
+
+	status.URL = info.URL
+	status.PendingUpdateCount = info.PendingUpdateCount
+	status.LastErrorDate = info.LastErrorDate
+	status.LastErrorMessage = info.LastErrorMessage
+
+	threshold := m.config.PendingUpdatesThreshold
+	if threshold > 0 && info.PendingUpdateCount > threshold {
+		status.Problems = append(status.Problems, fmt.Sprintf("%d pending updates", info.PendingUpdateCount))
+		events = append(events, WebhookEvent{Type: WebhookEventPendingUpdates, Info: info})
+	}
+
+	m.mu.Lock()
+	newError := info.LastErrorDate > m.lastErrorDate
+	m.mu.Unlock()
+
+	if newError {
+		events = append(events, WebhookEvent{Type: WebhookEventDeliveryError, Info: info})
+	}
+
+	errorWindow := m.config.ErrorWindow
+	if errorWindow == 0 {
+		errorWindow = DefaultWebhookErrorWindow
+	}
+
+	if info.LastErrorDate != 0 && status.CheckedAt.Sub(time.Unix(int64(info.LastErrorDate), 0)) < errorWindow {
+		status.Problems = append(status.Problems, "delivery error: "+info.LastErrorMessage)
+	}
+
+	if webhook := m.config.Webhook; webhook != nil && webhook.URL != nil && info.URL != webhook.URL.String() {
+		events = append(events, WebhookEvent{Type: WebhookEventURLChanged, Info: info})
+
+		if _, err := m.bot.Request(*webhook); err != nil {
+			status.Problems = append(status.Problems, "failed to set webhook: "+err.Error())
+			events = append(events, WebhookEvent{Type: WebhookEventReregistered, Info: info, Err: err})
+		} else {
+			status.URL = webhook.URL.String()
+			events = append(events, WebhookEvent{Type: WebhookEventReregistered, Info: info})
+		}
+	}
+
+	return m.update(status, events, info.LastErrorDate)
+}
+
+// update stores the status and raises events.
+func (m *WebhookMonitor) update(status WebhookStatus, events []WebhookEvent, lastErrorDate int) WebhookStatus {
+	status.Healthy = len(status.Problems) == 0
+
+	m.mu.Lock()
+	m.status = status
+	if lastErrorDate > m.lastErrorDate {
+		m.lastErrorDate = lastErrorDate
+	}
+	m.mu.Unlock()
+
+	for _, event := range events {
+		if m.bot.Debug {
+			log.Printf("Webhook event %s: %+v", event.Type, event.Info)
+		}
+
+		if m.config.OnEvent != nil {
+			m.config.OnEvent(event)
+		}
+	}
+
+	return status
+}
+
+// ServeHTTP reports the status of the most recent check as JSON. It responds
+// with 503 Service Unavailable if the webhook is unhealthy or has not been
+// checked yet.
+func (m *WebhookMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := m.Status()
+
+	code := http.StatusOK
+	if !status.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
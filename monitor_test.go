@@ -0,0 +1,90 @@
+package tgbotapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookMonitorHealthy(t *testing.T) {
+	client := &testClient{results: map[string]string{
+		"getWebhookInfo": `{"url":"https://example.com/hook","pending_update_count":1}`,
+	}}
+
+	var events []WebhookEvent
+	monitor := NewWebhookMonitor(newTestBot(client), WebhookMonitorConfig{
+		PendingUpdatesThreshold: 10,
+		OnEvent:                 func(event WebhookEvent) { events = append(events, event) },
+	})
+
+	w := httptest.NewRecorder()
+	monitor.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected unchecked monitor to be unhealthy, got %d", w.Code)
+	}
+
+	status := monitor.Check()
+	if !status.Healthy || len(events) != 0 {
+		t.Errorf("expected healthy status without events, got %+v, %v", status, events)
+	}
+
+	w = httptest.NewRecorder()
+	monitor.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected healthy response, got %d", w.Code)
+	}
+
+	var body WebhookStatus
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.URL != "https://example.com/hook" {
+		t.Errorf("unexpected body: %+v, %v", body, err)
+	}
+}
+
+func TestWebhookMonitorEvents(t *testing.T) {
+	lastError := time.Now().Add(time.Minute).Unix()
+
+	client := &testClient{results: map[string]string{
+		"getWebhookInfo": fmt.Sprintf(`{"url":"","pending_update_count":50,"last_error_date":%d,"last_error_message":"Connection refused"}`, lastError),
+	}}
+
+	webhook, _ := NewWebhook("https://example.com/hook")
+
+	var events []string
+	monitor := NewWebhookMonitor(newTestBot(client), WebhookMonitorConfig{
+		PendingUpdatesThreshold: 10,
+		Webhook:                 &webhook,
+		OnEvent:                 func(event WebhookEvent) { events = append(events, event.Type) },
+	})
+
+	status := monitor.Check()
+	if status.Healthy {
+		t.Error("expected unhealthy status")
+	}
+
+	expected := []string{
+		WebhookEventPendingUpdates,
+		WebhookEventDeliveryError,
+		WebhookEventURLChanged,
+		WebhookEventReregistered,
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+
+	requests := client.Requests()
+	if last := requests[len(requests)-1]; last.endpoint != "setWebhook" {
+		t.Errorf("expected webhook to be set again, got %s", last.endpoint)
+	}
+
+	events = nil
+	monitor.Check()
+
+	for _, event := range events {
+		if event == WebhookEventDeliveryError {
+			t.Error("expected the same delivery error to be reported once")
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package tgbotapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default values for CallbackPayloads
+const (
+	DefaultCallbackPayloadTTL  = 24 * time.Hour
+	DefaultCallbackExpiredText = "This button has expired."
+)
+
+// callbackPayloadKeyLength is the number of random bytes in a payload key.
+const callbackPayloadKeyLength = 12
+
+// CallbackPayloadStore stores callback payloads by key.
+//
+// Implementations must be safe for concurrent use. Backing it with a shared
+// store allows multiple replicas to resolve each other's buttons.
+type CallbackPayloadStore interface {
+	// SetPayload stores a payload for the duration of ttl.
+	SetPayload(key string, payload []byte, ttl time.Duration) error
+	// GetPayload returns the payload for a key. It returns false if there is
+	// no payload or it has expired.
+	GetPayload(key string) ([]byte, bool, error)
+}
+
+type storedPayload struct {
+	payload []byte
+	expires time.Time
+}
+
+// MemoryCallbackPayloadStore is a CallbackPayloadStore which keeps payloads
+// in memory.
+type MemoryCallbackPayloadStore struct {
+	mu        sync.Mutex
+	payloads  map[string]storedPayload
+	lastPrune time.Time
+}
+
+// NewMemoryCallbackPayloadStore creates a new MemoryCallbackPayloadStore.
+func NewMemoryCallbackPayloadStore() *MemoryCallbackPayloadStore {
+	return &MemoryCallbackPayloadStore{
+		payloads: make(map[string]storedPayload),
+	}
+}
+
+// SetPayload stores a payload for the duration of ttl.
+func (s *MemoryCallbackPayloadStore) SetPayload(key string, payload []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	// Expired payloads are only removed every so often to keep SetPayload
+	// cheap.
+	if now.Sub(s.lastPrune) >= ttl {
+		for k, p := range s.payloads {
+			if !now.Before(p.expires) {
+				delete(s.payloads, k)
+			}
+		}
+
+		s.lastPrune = now
+	}
+
+	s.payloads[key] = storedPayload{
+		payload: append([]byte(nil), payload...),
+		expires: now.Add(ttl),
+	}
+
+	return nil
+}
+
+// GetPayload returns the payload for a key.
+func (s *MemoryCallbackPayloadStore) GetPayload(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payloads[key]
+	if !ok || !time.Now().Before(p.expires) {
+		return nil, false, nil
+	}
+
+	return p.payload, true, nil
+}
+
+// CallbackPayload is a payload stored for a button.
+type CallbackPayload []byte
+
+// Decode decodes the payload into v.
+func (p CallbackPayload) Decode(v interface{}) error {
+	return json.Unmarshal(p, v)
+}
+
+// CallbackPayloadHandlerFunc handles a callback query for a button with a
+// stored payload.
+type CallbackPayloadHandlerFunc func(ctx context.Context, bot *BotAPI, update Update, payload CallbackPayload) (Chattable, error)
+
+// CallbackPayloads stores payloads too large for callback data and puts only
+// a short random key, prefixed with a namespace, in the callback data.
+type CallbackPayloads struct {
+	Store     CallbackPayloadStore
+	Namespace string
+	// TTL is how long payloads are kept. Defaults to
+	// DefaultCallbackPayloadTTL.
+	TTL time.Duration
+	// Expired is the answer to callback queries for buttons whose payload is
+	// gone. CallbackQueryID is filled in for every query.
+	Expired CallbackConfig
+}
+
+// NewCallbackPayloads creates a new CallbackPayloads for namespace which
+// keeps payloads in memory.
+func NewCallbackPayloads(namespace string) *CallbackPayloads {
+	return &CallbackPayloads{
+		Store:     NewMemoryCallbackPayloadStore(),
+		Namespace: namespace,
+		TTL:       DefaultCallbackPayloadTTL,
+		Expired:   NewCallbackWithAlert("", DefaultCallbackExpiredText),
+	}
+}
+
+func (p *CallbackPayloads) prefix() string {
+	return p.Namespace + ":"
+}
+
+// Put stores v, encoded as JSON, and returns the callback data referring to
+// it.
+func (p *CallbackPayloads) Put(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, callbackPayloadKeyLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	key := base64.RawURLEncoding.EncodeToString(b)
+
+	data := p.prefix() + key
+	if len(data) > MaxCallbackDataLength {
+		return "", errors.New(ErrCallbackDataTooLong)
+	}
+
+	ttl := p.TTL
+	if ttl <= 0 {
+		ttl = DefaultCallbackPayloadTTL
+	}
+
+	if err := p.Store.SetPayload(key, payload, ttl); err != nil {
+		return "", err
+	}
+
+	return data, nil
+}
+
+// Button creates an inline keyboard button with v stored as its payload.
+func (p *CallbackPayloads) Button(text string, v interface{}) (InlineKeyboardButton, error) {
+	data, err := p.Put(v)
+	if err != nil {
+		return InlineKeyboardButton{}, err
+	}
+
+	return NewInlineKeyboardButtonData(text, data), nil
+}
+
+// Get returns the payload referred to by callback data. It returns false if
+// the data is from another namespace or the payload is gone.
+func (p *CallbackPayloads) Get(data string) (CallbackPayload, bool, error) {
+	if !strings.HasPrefix(data, p.prefix()) {
+		return nil, false, nil
+	}
+
+	payload, ok, err := p.Store.GetPayload(data[len(p.prefix()):])
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	return payload, true, nil
+}
+
+// Filter matches callback queries with data in the namespace.
+func (p *CallbackPayloads) Filter() Filter {
+	return CallbackPrefixFilter(p.prefix())
+}
+
+// Handler returns a HandlerFunc which calls handler with the payload of a
+// callback query, or answers with Expired if the payload is gone.
+func (p *CallbackPayloads) Handler(handler CallbackPayloadHandlerFunc) HandlerFunc {
+	return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		if update.CallbackQuery == nil {
+			return nil, nil
+		}
+
+		payload, ok, err := p.Get(update.CallbackQuery.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			expired := p.Expired
+			expired.CallbackQueryID = update.CallbackQuery.ID
+			return expired, nil
+		}
+
+		return handler(ctx, bot, update, payload)
+	}
+}
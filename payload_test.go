@@ -0,0 +1,71 @@
+package tgbotapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSearch struct {
+	Query   string   `json:"query"`
+	Filters []string `json:"filters"`
+	Page    int      `json:"page"`
+}
+
+func TestCallbackPayloads(t *testing.T) {
+	payloads := NewCallbackPayloads("search")
+
+	search := testSearch{Query: strings.Repeat("long query ", 10), Filters: []string{"new", "cheap"}, Page: 3}
+
+	button, err := payloads.Button("Next", search)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := *button.CallbackData
+	if !strings.HasPrefix(data, "search:") || len(data) > MaxCallbackDataLength {
+		t.Errorf("unexpected callback data %q", data)
+	}
+
+	var resolved testSearch
+
+	d := NewDispatcher()
+	d.HandleCallbackPayloads(payloads, func(ctx context.Context, bot *BotAPI, update Update, payload CallbackPayload) (Chattable, error) {
+		return nil, payload.Decode(&resolved)
+	})
+
+	bot := newTestBot(&testClient{})
+
+	if _, err := d.Dispatch(context.Background(), bot, newTestCallbackUpdate(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if resolved.Query != search.Query || len(resolved.Filters) != 2 || resolved.Page != 3 {
+		t.Errorf("unexpected payload %+v", resolved)
+	}
+
+	c, err := d.Dispatch(context.Background(), bot, newTestCallbackUpdate("search:missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, ok := c.(CallbackConfig)
+	if !ok || expired.CallbackQueryID != "5" || expired.Text != DefaultCallbackExpiredText {
+		t.Errorf("expected expired answer, got %#v", c)
+	}
+}
+
+func TestMemoryCallbackPayloadStoreExpiry(t *testing.T) {
+	store := NewMemoryCallbackPayloadStore()
+
+	if err := store.SetPayload("a", []byte("1"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok, _ := store.GetPayload("a"); ok {
+		t.Error("expected payload to expire")
+	}
+}
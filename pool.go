@@ -0,0 +1,119 @@
+package tgbotapi
+
+import (
+	"context"
+	"sync"
+)
+
+// Default values for WorkerPoolConfig
+const (
+	DefaultPoolWorkers   = 8
+	DefaultPoolQueueSize = 100
+)
+
+// WorkerPoolConfig contains information about how a WorkerPool processes
+// updates.
+type WorkerPoolConfig struct {
+	// Workers is the number of updates processed in parallel. Defaults to
+	// DefaultPoolWorkers.
+	Workers int
+	// QueueSize is the number of updates each worker can have waiting.
+	// Defaults to DefaultPoolQueueSize.
+	QueueSize int
+	// DropWhenFull drops updates for a worker with a full queue instead of
+	// waiting for room.
+	DropWhenFull bool
+}
+
+// WorkerPool processes updates concurrently while keeping updates from the
+// same chat in order.
+//
+// Updates are sharded by chat, or by sender for updates without a chat, so
+// every update from a chat is processed by the same worker one at a time.
+// Updates from different chats are processed in parallel.
+type WorkerPool struct {
+	bot     *BotAPI
+	handler HandlerFunc
+	config  WorkerPoolConfig
+}
+
+// NewWorkerPool creates a new WorkerPool calling handler for every update.
+func NewWorkerPool(bot *BotAPI, handler HandlerFunc, config WorkerPoolConfig) *WorkerPool {
+	if config.Workers <= 0 {
+		config.Workers = DefaultPoolWorkers
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultPoolQueueSize
+	}
+
+	return &WorkerPool{
+		bot:     bot,
+		handler: handler,
+		config:  config,
+	}
+}
+
+// Run processes every update from updates until the channel is closed and
+// all queued updates have been processed. Chattables returned by the
+// handler are sent using Request.
+func (p *WorkerPool) Run(updates UpdatesChannel) {
+	queues := make([]chan Update, p.config.Workers)
+
+	var wg sync.WaitGroup
+	wg.Add(len(queues))
+
+	for i := range queues {
+		queues[i] = make(chan Update, p.config.QueueSize)
+
+		go func(queue <-chan Update) {
+			defer wg.Done()
+
+			for update := range queue {
+				p.process(update)
+			}
+		}(queues[i])
+	}
+
+	for update := range updates {
+		queue := queues[shardKey(update)%uint64(len(queues))]
+
+		if !p.config.DropWhenFull {
+			queue <- update
+			continue
+		}
+
+		select {
+		case queue <- update:
+		default:
+			log.Printf("Dropping update %d, worker queue is full", update.UpdateID)
+		}
+	}
+
+	for _, queue := range queues {
+		close(queue)
+	}
+
+	wg.Wait()
+}
+
+// process handles a single update, recovering from panics in the handler so
+// the worker keeps running.
+func (p *WorkerPool) process(update Update) {
+	serveUpdate(context.Background(), p.bot, recoverHandler(p.handler), update)
+}
+
+// shardKey returns the key used to assign an update to a worker. Updates
+// from the same chat, or from the same user when there is no chat, have the
+// same key.
+func shardKey(update Update) uint64 {
+	if chat := update.FromChat(); chat != nil {
+		return uint64(chat.ID)
+	}
+
+	if user := update.SentFrom(); user != nil {
+		return uint64(user.ID)
+	}
+
+	return uint64(update.UpdateID)
+}
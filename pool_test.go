@@ -0,0 +1,122 @@
+package tgbotapi
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolOrdering(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		chats  = make(map[int64][]int)
+		active = make(map[int64]bool)
+	)
+
+	handler := func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		chatID := update.Message.Chat.ID
+
+		mu.Lock()
+		if active[chatID] {
+			t.Errorf("chat %d processed concurrently", chatID)
+		}
+		active[chatID] = true
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		active[chatID] = false
+		chats[chatID] = append(chats[chatID], update.UpdateID)
+		mu.Unlock()
+
+		return nil, nil
+	}
+
+	pool := NewWorkerPool(newTestBot(&testClient{}), handler, WorkerPoolConfig{Workers: 4})
+
+	updates := make(chan Update, 40)
+	for i := 0; i < 40; i++ {
+		updates <- Update{
+			UpdateID: i,
+			Message:  &Message{Chat: &Chat{ID: int64(i % 5)}},
+		}
+	}
+	close(updates)
+
+	pool.Run(updates)
+
+	for chatID, ids := range chats {
+		if len(ids) != 8 {
+			t.Errorf("expected 8 updates for chat %d, got %d", chatID, len(ids))
+		}
+
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Errorf("updates for chat %d out of order: %v", chatID, ids)
+				break
+			}
+		}
+	}
+}
+
+func TestWorkerPoolParallel(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+
+	handler := func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		started <- struct{}{}
+		<-release
+		return nil, nil
+	}
+
+	pool := NewWorkerPool(newTestBot(&testClient{}), handler, WorkerPoolConfig{Workers: 2})
+
+	updates := make(chan Update, 2)
+	updates <- Update{UpdateID: 1, Message: &Message{Chat: &Chat{ID: 0}}}
+	updates <- Update{UpdateID: 2, Message: &Message{Chat: &Chat{ID: 1}}}
+	close(updates)
+
+	done := make(chan struct{})
+	go func() {
+		pool.Run(updates)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("expected different chats to be processed in parallel")
+		}
+	}
+
+	close(release)
+	<-done
+}
+
+func TestWorkerPoolRecover(t *testing.T) {
+	client := &testClient{}
+
+	handler := func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		if update.UpdateID == 1 {
+			panic("test panic")
+		}
+
+		return NewMessage(update.Message.Chat.ID, "ok"), nil
+	}
+
+	pool := NewWorkerPool(newTestBot(client), handler, WorkerPoolConfig{Workers: 1})
+
+	updates := make(chan Update, 2)
+	updates <- Update{UpdateID: 1, Message: &Message{Chat: &Chat{ID: 3}}}
+	updates <- Update{UpdateID: 2, Message: &Message{Chat: &Chat{ID: 3}}}
+	close(updates)
+
+	pool.Run(updates)
+
+	if requests := client.Requests(); len(requests) != 1 {
+		t.Errorf("expected worker to keep running after panic, got %v", requests)
+	}
+}
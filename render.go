@@ -0,0 +1,412 @@
+package tgbotapi
+
+import (
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// RenderHTML formats text with entities as ModeHTML text.
+//
+// Entities which overlap without being nested are split, so the result is
+// always valid. Entities Telegram detects by itself, such as mentions and
+// URLs, are left as plain text.
+func RenderHTML(text string, entities []MessageEntity) string {
+	return renderEntities(text, entities, htmlRenderer{})
+}
+
+// RenderMarkdownV2 formats text with entities as ModeMarkdownV2 text.
+//
+// Entities which overlap without being nested are split, so the result is
+// always valid. Entities Telegram detects by itself, such as mentions and
+// URLs, are left as plain text.
+func RenderMarkdownV2(text string, entities []MessageEntity) string {
+	return renderEntities(text, entities, &markdownV2Renderer{})
+}
+
+// HTML returns the text, or the caption if the message has no text, with its
+// formatting as ModeHTML text.
+func (m *Message) HTML() string {
+	text, entities := m.textEntities()
+	return RenderHTML(text, entities)
+}
+
+// MarkdownV2 returns the text, or the caption if the message has no text,
+// with its formatting as ModeMarkdownV2 text.
+func (m *Message) MarkdownV2() string {
+	text, entities := m.textEntities()
+	return RenderMarkdownV2(text, entities)
+}
+
+// entityRenderer writes formatting for a parse mode.
+type entityRenderer interface {
+	open(e MessageEntity) string
+	close(e MessageEntity) string
+	// escape escapes text within the given open entities.
+	escape(text string, open []MessageEntity) string
+}
+
+// renderEntities renders text with entities, splitting entities where they
+// overlap so formatting is always properly nested.
+func renderEntities(text string, entities []MessageEntity, renderer entityRenderer) string {
+	encoded := utf16.Encode([]rune(text))
+
+	type span struct {
+		entity     MessageEntity
+		start, end int
+		index      int
+	}
+
+	var spans []span
+	boundaries := []int{0, len(encoded)}
+
+	for i, e := range entities {
+		start, end := e.Offset, e.Offset+e.Length
+		if start < 0 || end > len(encoded) || start >= end {
+			continue
+		}
+
+		if !renderableEntity(e) {
+			continue
+		}
+
+		spans = append(spans, span{e, start, end, i})
+		boundaries = append(boundaries, start, end)
+
+		// The newline before a blockquote is split off, so entities
+		// closed for the blockquote are closed before the line ends.
+		if e.Type == "blockquote" && start > 0 && encoded[start-1] == '\n' {
+			boundaries = append(boundaries, start-1)
+		}
+	}
+
+	sort.Ints(boundaries)
+
+	var (
+		out   strings.Builder
+		stack []span
+	)
+
+	closeTo := func(depth int) {
+		for len(stack) > depth {
+			out.WriteString(renderer.close(stack[len(stack)-1].entity))
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	for i := 0; i+1 < len(boundaries); i++ {
+		start, end := boundaries[i], boundaries[i+1]
+		if start == end {
+			continue
+		}
+
+		covers := func(s span) bool {
+			return s.start <= start && s.end >= end
+		}
+
+		// Keep the open entities which still apply, and close everything
+		// from the first one which does not.
+		depth := 0
+		for depth < len(stack) && covers(stack[depth]) {
+			depth++
+		}
+
+		// A blockquote has to be the outermost entity, so everything open
+		// is closed before one starts and reopened inside it.
+		lineEnd := false
+		for _, s := range spans {
+			if s.entity.Type != "blockquote" {
+				continue
+			}
+
+			if s.start == start {
+				depth = 0
+			}
+
+			if s.start == end && end-start == 1 && encoded[start] == '\n' {
+				lineEnd = true
+			}
+		}
+
+		if lineEnd {
+			closeTo(0)
+			out.WriteString(renderer.escape("\n", nil))
+			continue
+		}
+
+		closeTo(depth)
+
+		var opening []span
+		for _, s := range spans {
+			if !covers(s) {
+				continue
+			}
+
+			open := false
+			for _, o := range stack {
+				if o.index == s.index {
+					open = true
+					break
+				}
+			}
+
+			if !open {
+				opening = append(opening, s)
+			}
+		}
+
+		// Blockquotes are opened first. Other entities ending last are
+		// opened next, so they need to be split the least. Code can't
+		// contain other entities, so it is opened last among entities
+		// ending together.
+		sort.SliceStable(opening, func(i, j int) bool {
+			if quote := opening[i].entity.Type == "blockquote"; quote != (opening[j].entity.Type == "blockquote") {
+				return quote
+			}
+
+			if opening[i].end != opening[j].end {
+				return opening[i].end > opening[j].end
+			}
+
+			return !codeEntity(opening[i].entity) && codeEntity(opening[j].entity)
+		})
+
+		for _, s := range opening {
+			out.WriteString(renderer.open(s.entity))
+			stack = append(stack, s)
+		}
+
+		open := make([]MessageEntity, len(stack))
+		for i, s := range stack {
+			open[i] = s.entity
+		}
+
+		out.WriteString(renderer.escape(string(utf16.Decode(encoded[start:end])), open))
+	}
+
+	closeTo(0)
+
+	return out.String()
+}
+
+// codeEntity reports whether an entity is code, which can't contain other
+// entities.
+func codeEntity(e MessageEntity) bool {
+	return e.Type == "code" || e.Type == "pre"
+}
+
+// renderableEntity reports whether an entity has formatting which needs to be
+// rendered.
+func renderableEntity(e MessageEntity) bool {
+	switch e.Type {
+	case "bold", "italic", "underline", "strikethrough", "spoiler", "code",
+		"pre", "text_link", "custom_emoji", "blockquote":
+		return true
+	case "text_mention":
+		return e.User != nil
+	}
+
+	return false
+}
+
+type htmlRenderer struct{}
+
+func (htmlRenderer) open(e MessageEntity) string {
+	switch e.Type {
+	case "bold":
+		return "<b>"
+	case "italic":
+		return "<i>"
+	case "underline":
+		return "<u>"
+	case "strikethrough":
+		return "<s>"
+	case "spoiler":
+		return "<tg-spoiler>"
+	case "code":
+		return "<code>"
+	case "pre":
+		if e.Language != "" {
+			return `<pre><code class="language-` + html.EscapeString(e.Language) + `">`
+		}
+
+		return "<pre>"
+	case "text_link":
+		return `<a href="` + html.EscapeString(e.URL) + `">`
+	case "text_mention":
+		return `<a href="tg://user?id=` + strconv.FormatInt(e.User.ID, 10) + `">`
+	case "custom_emoji":
+		return `<tg-emoji emoji-id="` + html.EscapeString(e.CustomEmojiID) + `">`
+	case "blockquote":
+		return "<blockquote>"
+	}
+
+	return ""
+}
+
+func (htmlRenderer) close(e MessageEntity) string {
+	switch e.Type {
+	case "bold":
+		return "</b>"
+	case "italic":
+		return "</i>"
+	case "underline":
+		return "</u>"
+	case "strikethrough":
+		return "</s>"
+	case "spoiler":
+		return "</tg-spoiler>"
+	case "code":
+		return "</code>"
+	case "pre":
+		if e.Language != "" {
+			return "</code></pre>"
+		}
+
+		return "</pre>"
+	case "text_link", "text_mention":
+		return "</a>"
+	case "custom_emoji":
+		return "</tg-emoji>"
+	case "blockquote":
+		return "</blockquote>"
+	}
+
+	return ""
+}
+
+func (htmlRenderer) escape(text string, open []MessageEntity) string {
+	return EscapeText(ModeHTML, text)
+}
+
+type markdownV2Renderer struct {
+	// last is the last marker written, used to separate italic and
+	// underline markers.
+	last string
+	// quoteLine is set when text in a blockquote ended with a newline, so
+	// the next line needs to start with > if the blockquote continues.
+	quoteLine bool
+}
+
+func (r *markdownV2Renderer) marker(s string) string {
+	// __ is always treated as underline, so an italic marker next to an
+	// underline marker needs a character Telegram ignores between them.
+	if strings.HasPrefix(s, "_") && strings.HasSuffix(r.last, "_") {
+		s = "\r" + s
+	}
+
+	if r.quoteLine {
+		s = ">" + s
+		r.quoteLine = false
+	}
+
+	r.last = s
+
+	return s
+}
+
+func (r *markdownV2Renderer) open(e MessageEntity) string {
+	switch e.Type {
+	case "bold":
+		return r.marker("*")
+	case "italic":
+		return r.marker("_")
+	case "underline":
+		return r.marker("__")
+	case "strikethrough":
+		return r.marker("~")
+	case "spoiler":
+		return r.marker("||")
+	case "code":
+		return r.marker("`")
+	case "pre":
+		return r.marker("```" + e.Language + "\n")
+	case "text_link", "text_mention":
+		return r.marker("[")
+	case "custom_emoji":
+		return r.marker("![")
+	case "blockquote":
+		return r.marker(">")
+	}
+
+	return ""
+}
+
+func (r *markdownV2Renderer) close(e MessageEntity) string {
+	switch e.Type {
+	case "bold":
+		return r.marker("*")
+	case "italic":
+		return r.marker("_")
+	case "underline":
+		return r.marker("__")
+	case "strikethrough":
+		return r.marker("~")
+	case "spoiler":
+		return r.marker("||")
+	case "code":
+		return r.marker("`")
+	case "pre":
+		return r.marker("```")
+	case "text_link":
+		return r.marker("](" + escapeMarkdownV2URL(e.URL) + ")")
+	case "text_mention":
+		return r.marker("](tg://user?id=" + strconv.FormatInt(e.User.ID, 10) + ")")
+	case "custom_emoji":
+		return r.marker("](tg://emoji?id=" + escapeMarkdownV2URL(e.CustomEmojiID) + ")")
+	case "blockquote":
+		// A blockquote ends with the last line starting with >.
+		r.quoteLine = false
+		return ""
+	}
+
+	return ""
+}
+
+func (r *markdownV2Renderer) escape(text string, open []MessageEntity) string {
+	if text == "" {
+		return ""
+	}
+
+	code, quote := false, false
+	for _, e := range open {
+		switch e.Type {
+		case "code", "pre":
+			code = true
+		case "blockquote":
+			quote = true
+		}
+	}
+
+	if code {
+		text = strings.NewReplacer("`", "\\`", "\\", "\\\\").Replace(text)
+	} else {
+		text = EscapeText(ModeMarkdownV2, text)
+	}
+
+	if quote {
+		// A trailing newline may end the blockquote, so its > is only
+		// written once more quoted text follows.
+		line := strings.TrimSuffix(text, "\n")
+		quoted := strings.ReplaceAll(line, "\n", "\n>") + text[len(line):]
+
+		if r.quoteLine {
+			quoted = ">" + quoted
+		}
+
+		r.quoteLine = len(line) < len(text)
+		text = quoted
+	}
+
+	r.last = ""
+
+	return text
+}
+
+// escapeMarkdownV2URL escapes the characters which must be escaped in the
+// URL part of a MarkdownV2 link.
+func escapeMarkdownV2URL(url string) string {
+	return strings.NewReplacer(")", "\\)", "\\", "\\\\").Replace(url)
+}
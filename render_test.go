@@ -0,0 +1,220 @@
+package tgbotapi
+
+import "testing"
+
+func TestRenderHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []MessageEntity
+		expected string
+	}{
+		{
+			name:     "escaped",
+			text:     "a < b & c",
+			expected: "a &lt; b &amp; c",
+		},
+		{
+			name: "nested",
+			text: "😀 bold italic",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 3, Length: 11},
+				{Type: "italic", Offset: 8, Length: 6},
+			},
+			expected: "😀 <b>bold <i>italic</i></b>",
+		},
+		{
+			name: "overlapping",
+			text: "abcdef",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 0, Length: 4},
+				{Type: "italic", Offset: 2, Length: 4},
+			},
+			expected: "<b>ab<i>cd</i></b><i>ef</i>",
+		},
+		{
+			name: "links",
+			text: "docs you 👍 url",
+			entities: []MessageEntity{
+				{Type: "text_link", Offset: 0, Length: 4, URL: "https://example.com/?a=1&b=\"2\""},
+				{Type: "text_mention", Offset: 5, Length: 3, User: &User{ID: 4}},
+				{Type: "custom_emoji", Offset: 9, Length: 2, CustomEmojiID: "42"},
+				{Type: "url", Offset: 12, Length: 3},
+			},
+			expected: `<a href="https://example.com/?a=1&amp;b=&#34;2&#34;">docs</a> <a href="tg://user?id=4">you</a> <tg-emoji emoji-id="42">👍</tg-emoji> url`,
+		},
+		{
+			name: "pre",
+			text: "x <y>\nfmt.Println()",
+			entities: []MessageEntity{
+				{Type: "code", Offset: 0, Length: 5},
+				{Type: "pre", Offset: 6, Length: 13, Language: "go"},
+			},
+			expected: "<code>x &lt;y&gt;</code>\n<pre><code class=\"language-go\">fmt.Println()</code></pre>",
+		},
+		{
+			name: "code and bold",
+			text: "0",
+			entities: []MessageEntity{
+				{Type: "code", Offset: 0, Length: 1},
+				{Type: "bold", Offset: 0, Length: 1},
+			},
+			expected: "<b><code>0</code></b>",
+		},
+		{
+			name: "invalid entity",
+			text: "abc",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 2, Length: 5},
+			},
+			expected: "abc",
+		},
+	}
+
+	for _, test := range tests {
+		if html := RenderHTML(test.text, test.entities); html != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, html)
+		}
+	}
+}
+
+func TestRenderMarkdownV2(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []MessageEntity
+		expected string
+	}{
+		{
+			name:     "escaped",
+			text:     "1. a_b \\ c!",
+			expected: "1\\. a\\_b \\\\ c\\!",
+		},
+		{
+			name: "overlapping",
+			text: "abcdef",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 0, Length: 4},
+				{Type: "strikethrough", Offset: 2, Length: 4},
+			},
+			expected: "*ab~cd~*~ef~",
+		},
+		{
+			name: "italic underline",
+			text: "ab",
+			entities: []MessageEntity{
+				{Type: "italic", Offset: 0, Length: 2},
+				{Type: "underline", Offset: 0, Length: 2},
+			},
+			expected: "_\r__ab__\r_",
+		},
+		{
+			name: "links",
+			text: "docs (you) 👍",
+			entities: []MessageEntity{
+				{Type: "text_link", Offset: 0, Length: 4, URL: "https://example.com/(a)"},
+				{Type: "text_mention", Offset: 6, Length: 3, User: &User{ID: 4}},
+				{Type: "custom_emoji", Offset: 11, Length: 2, CustomEmojiID: "42"},
+			},
+			expected: "[docs](https://example.com/(a\\)) \\([you](tg://user?id=4)\\) ![👍](tg://emoji?id=42)",
+		},
+		{
+			name: "code",
+			text: "a_`b`\nx := `\\`",
+			entities: []MessageEntity{
+				{Type: "code", Offset: 0, Length: 5},
+				{Type: "pre", Offset: 6, Length: 8, Language: "go"},
+			},
+			expected: "`a_\\`b\\``\n```go\nx := \\`\\\\\\````",
+		},
+		{
+			name: "blockquote",
+			text: "a.\nb.\nc",
+			entities: []MessageEntity{
+				{Type: "blockquote", Offset: 0, Length: 5},
+			},
+			expected: ">a\\.\n>b\\.\nc",
+		},
+		{
+			name: "blockquote ending with newline",
+			text: "quote line\nnormal",
+			entities: []MessageEntity{
+				{Type: "blockquote", Offset: 0, Length: 11},
+			},
+			expected: ">quote line\nnormal",
+		},
+		{
+			name: "blockquote continuing after newline",
+			text: "a\nb\nc",
+			entities: []MessageEntity{
+				{Type: "blockquote", Offset: 0, Length: 5},
+				{Type: "bold", Offset: 2, Length: 1},
+			},
+			expected: ">a\n>*b*\n>c",
+		},
+		{
+			name: "code and bold",
+			text: "0",
+			entities: []MessageEntity{
+				{Type: "code", Offset: 0, Length: 1},
+				{Type: "bold", Offset: 0, Length: 1},
+			},
+			expected: "*`0`*",
+		},
+		{
+			name: "bold blockquote",
+			text: "hello",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 0, Length: 5},
+				{Type: "blockquote", Offset: 0, Length: 5},
+			},
+			expected: ">*hello*",
+		},
+		{
+			name: "bold across blockquote",
+			text: "hello\nworld",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 0, Length: 11},
+				{Type: "blockquote", Offset: 0, Length: 5},
+			},
+			expected: ">*hello**\nworld*",
+		},
+		{
+			name: "bold into blockquote",
+			text: "a\nb",
+			entities: []MessageEntity{
+				{Type: "bold", Offset: 0, Length: 3},
+				{Type: "blockquote", Offset: 2, Length: 1},
+			},
+			expected: "*a*\n>*b*",
+		},
+	}
+
+	for _, test := range tests {
+		md := RenderMarkdownV2(test.text, test.entities)
+		if md != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, md)
+		}
+
+		if errs := ValidateFormatting(ModeMarkdownV2, md); len(errs) != 0 {
+			t.Errorf("%s: invalid MarkdownV2 %q: %v", test.name, md, errs)
+		}
+	}
+}
+
+func TestMessageRender(t *testing.T) {
+	message := Message{
+		Caption: "Hi Bob",
+		CaptionEntities: []MessageEntity{
+			{Type: "bold", Offset: 3, Length: 3},
+		},
+	}
+
+	if html := message.HTML(); html != "Hi <b>Bob</b>" {
+		t.Errorf("unexpected HTML %q", html)
+	}
+
+	if md := message.MarkdownV2(); md != "Hi *Bob*" {
+		t.Errorf("unexpected MarkdownV2 %q", md)
+	}
+}
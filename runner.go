@@ -0,0 +1,381 @@
+package tgbotapi
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Constant values for RunnerConfig.Mode
+const (
+	// RunnerModePolling receives updates using getUpdates.
+	RunnerModePolling = "polling"
+	// RunnerModeWebhook receives updates using a webhook.
+	RunnerModeWebhook = "webhook"
+)
+
+// RunnerConfig contains information about how a Runner receives updates.
+type RunnerConfig struct {
+	// Mode is either RunnerModePolling or RunnerModeWebhook.
+	Mode string
+	// DropPendingUpdates drops all updates waiting on Telegram's side when
+	// the webhook is set or deleted on start.
+	DropPendingUpdates bool
+
+	// Update is used to fetch updates in polling mode.
+	Update UpdateConfig
+
+	// Webhook is registered with Telegram in webhook mode. Its URL must be
+	// set.
+	Webhook WebhookConfig
+	// ListenAddr is the address the webhook server listens on, such as
+	// ":8443".
+	ListenAddr string
+	// Path is the pattern the webhook is served on. If empty, the path of
+	// the webhook URL is used.
+	Path string
+	// CertFile and KeyFile are used to serve the webhook using TLS. If they
+	// and TLSConfig are empty, plain HTTP is served, such as when running
+	// behind a reverse proxy.
+	CertFile string
+	KeyFile  string
+	// TLSConfig is used to serve the webhook using TLS.
+	TLSConfig *tls.Config
+	// Allowlist rejects webhook requests not coming from its networks.
+	//
+	// optional
+	Allowlist *IPAllowlist
+}
+
+// NewPollingRunnerConfig creates a RunnerConfig for receiving updates using
+// getUpdates.
+func NewPollingRunnerConfig(config UpdateConfig) RunnerConfig {
+	return RunnerConfig{
+		Mode:   RunnerModePolling,
+		Update: config,
+	}
+}
+
+// NewWebhookRunnerConfig creates a RunnerConfig for receiving updates using a
+// webhook.
+//
+// webhook is registered with Telegram, listenAddr is the address the webhook
+// server listens on.
+func NewWebhookRunnerConfig(webhook WebhookConfig, listenAddr string) RunnerConfig {
+	return RunnerConfig{
+		Mode:       RunnerModeWebhook,
+		Webhook:    webhook,
+		ListenAddr: listenAddr,
+	}
+}
+
+// Runner receives updates either by polling or through a webhook, and
+// provides them on the same UpdatesChannel regardless of the mode.
+//
+// When started, it makes sure the webhook state on Telegram's side matches
+// the configured mode, so switching between modes requires no other calls.
+type Runner struct {
+	bot    *BotAPI
+	config RunnerConfig
+
+	mu       sync.Mutex
+	started  bool
+	stop     chan interface{}
+	stopOnce sync.Once
+	done     chan struct{}
+	updates  chan Update
+	closed   bool
+	closeMu  sync.RWMutex
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewRunner creates a new Runner.
+func NewRunner(bot *BotAPI, config RunnerConfig) *Runner {
+	return &Runner{
+		bot:    bot,
+		config: config,
+		stop:   make(chan interface{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start reconciles the webhook state with Telegram and starts receiving
+// updates. A Runner can only be started once.
+//
+// In webhook mode, the webhook server starts listening before the webhook is
+// set, so Telegram is never left sending updates to an address which can't
+// be listened on.
+func (r *Runner) Start() (UpdatesChannel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started {
+		return nil, errors.New("runner already started")
+	}
+
+	if r.config.Mode == RunnerModeWebhook {
+		if err := r.listen(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.reconcile(); err != nil {
+		if r.listener != nil {
+			r.listener.Close()
+			r.listener, r.server = nil, nil
+		}
+
+		return nil, err
+	}
+
+	r.updates = make(chan Update, r.bot.Buffer)
+
+	switch r.config.Mode {
+	case RunnerModePolling:
+		go r.poll()
+	case RunnerModeWebhook:
+		r.serve()
+	}
+
+	r.started = true
+
+	return r.updates, nil
+}
+
+// Stop stops receiving updates and closes the UpdatesChannel. It waits for
+// in-flight webhook requests or the current getUpdates request to finish,
+// until ctx is done.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	started := r.started
+	r.mu.Unlock()
+
+	if !started {
+		return errors.New("runner not started")
+	}
+
+	r.stopOnce.Do(func() {
+		close(r.stop)
+
+		if r.server != nil {
+			go func() {
+				// Shutdown only returns early if ctx is done, in which case
+				// the remaining connections are closed forcefully.
+				if err := r.server.Shutdown(ctx); err != nil {
+					r.server.Close()
+				}
+
+				// Handlers may still be running after a forceful close, so
+				// wait for them to stop sending before closing the channel.
+				r.closeMu.Lock()
+				r.closed = true
+				close(r.updates)
+				r.closeMu.Unlock()
+
+				close(r.done)
+			}()
+		}
+	})
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Addr returns the address the webhook server is listening on, or nil when
+// not running in webhook mode.
+func (r *Runner) Addr() net.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.listener == nil {
+		return nil
+	}
+
+	return r.listener.Addr()
+}
+
+// reconcile sets or deletes the webhook so it matches the configured mode.
+func (r *Runner) reconcile() error {
+	switch r.config.Mode {
+	case RunnerModePolling:
+		info, err := r.bot.GetWebhookInfo()
+		if err != nil {
+			return err
+		}
+
+		if info.IsSet() || r.config.DropPendingUpdates {
+			if r.bot.Debug {
+				log.Printf("Deleting webhook %s", info.URL)
+			}
+
+			_, err := r.bot.Request(DeleteWebhookConfig{
+				DropPendingUpdates: r.config.DropPendingUpdates,
+			})
+
+			return err
+		}
+
+		return nil
+	case RunnerModeWebhook:
+		if r.config.Webhook.URL == nil {
+			return errors.New(ErrBadURL)
+		}
+
+		info, err := r.bot.GetWebhookInfo()
+		if err != nil {
+			return err
+		}
+
+		webhook := r.config.Webhook
+		webhook.DropPendingUpdates = webhook.DropPendingUpdates || r.config.DropPendingUpdates
+
+		if webhookNeedsUpdate(info, webhook) {
+			if r.bot.Debug {
+				log.Printf("Setting webhook %s", webhook.URL)
+			}
+
+			_, err := r.bot.Request(webhook)
+
+			return err
+		}
+
+		return nil
+	default:
+		return errors.New("unknown runner mode: " + r.config.Mode)
+	}
+}
+
+// webhookNeedsUpdate checks if the webhook currently set differs from the
+// config. Certificates cannot be compared, so they are always uploaded.
+func webhookNeedsUpdate(info WebhookInfo, config WebhookConfig) bool {
+	if info.URL != config.URL.String() ||
+		config.Certificate != nil ||
+		config.DropPendingUpdates {
+		return true
+	}
+
+	if config.IPAddress != "" && config.IPAddress != info.IPAddress {
+		return true
+	}
+
+	if config.MaxConnections != 0 && config.MaxConnections != info.MaxConnections {
+		return true
+	}
+
+	if len(config.AllowedUpdates) == 0 {
+		return false
+	}
+
+	if len(config.AllowedUpdates) != len(info.AllowedUpdates) {
+		return true
+	}
+
+	allowed := make(map[string]bool)
+	for _, updateType := range info.AllowedUpdates {
+		allowed[updateType] = true
+	}
+
+	for _, updateType := range config.AllowedUpdates {
+		if !allowed[updateType] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Runner) poll() {
+	defer close(r.done)
+
+	offset := r.bot.pollUpdates(r.config.Update, r.updates, r.stop)
+
+	// Telegram only forgets updates once a request with a higher offset is
+	// made, so confirm what was delivered to avoid receiving it again.
+	if offset != r.config.Update.Offset {
+		if _, err := r.bot.Request(UpdateConfig{Offset: offset, Limit: 1}); err != nil {
+			log.Printf("Failed to confirm updates: %s", err)
+		}
+	}
+}
+
+// listen creates the webhook server and its listener without serving yet.
+func (r *Runner) listen() error {
+	path := r.config.Path
+	if path == "" {
+		path = r.config.Webhook.URL.Path
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, r.serveWebhook)
+
+	listener, err := net.Listen("tcp", r.config.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	r.listener = listener
+	r.server = &http.Server{
+		Handler:   mux,
+		TLSConfig: r.config.TLSConfig,
+	}
+
+	return nil
+}
+
+// serve serves the webhook on the listener created by listen.
+func (r *Runner) serve() {
+	listener := r.listener
+	useTLS := r.config.TLSConfig != nil || r.config.CertFile != ""
+
+	go func() {
+		var err error
+		if useTLS {
+			err = r.server.ServeTLS(listener, r.config.CertFile, r.config.KeyFile)
+		} else {
+			err = r.server.Serve(listener)
+		}
+
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("Webhook server failed: %s", err)
+		}
+	}()
+}
+
+func (r *Runner) serveWebhook(w http.ResponseWriter, req *http.Request) {
+	if r.config.Allowlist != nil && !r.config.Allowlist.IsAllowed(req) {
+		writeForbidden(w)
+		return
+	}
+
+	update, err := r.bot.HandleUpdate(req)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	r.closeMu.RLock()
+	defer r.closeMu.RUnlock()
+
+	if r.closed {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	select {
+	case r.updates <- *update:
+	case <-r.stop:
+		// Telegram redelivers the update once we are running again.
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,148 @@
+package tgbotapi
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunnerPolling(t *testing.T) {
+	client := &testClient{results: map[string]string{
+		"getWebhookInfo": `{"url":"https://example.com/hook","pending_update_count":0}`,
+		"getUpdates":     `[{"update_id":5,"message":{"message_id":1,"date":0,"chat":{"id":3,"type":"private"},"text":"hi"}}]`,
+	}}
+	bot := newTestBot(client)
+
+	runner := NewRunner(bot, NewPollingRunnerConfig(NewUpdate(0)))
+
+	updates, err := runner.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := <-updates
+	if update.UpdateID != 5 {
+		t.Errorf("unexpected update: %d", update.UpdateID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := runner.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for range updates {
+	}
+
+	requests := client.Requests()
+
+	if requests[1].endpoint != "deleteWebhook" {
+		t.Errorf("expected webhook to be deleted, got %s", requests[1].endpoint)
+	}
+
+	last := requests[len(requests)-1]
+	if last.endpoint != "getUpdates" || last.values.Get("offset") != "6" {
+		t.Errorf("expected updates to be confirmed, got %s %v", last.endpoint, last.values)
+	}
+}
+
+func TestRunnerWebhook(t *testing.T) {
+	client := &testClient{results: map[string]string{
+		"getWebhookInfo": `{"url":"","pending_update_count":0}`,
+	}}
+	bot := newTestBot(client)
+
+	webhook, _ := NewWebhook("https://example.com/hook")
+	config := NewWebhookRunnerConfig(webhook, "127.0.0.1:0")
+	config.DropPendingUpdates = true
+
+	runner := NewRunner(bot, config)
+
+	updates, err := runner.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 2 ||
+		requests[1].endpoint != "setWebhook" ||
+		requests[1].values.Get("url") != "https://example.com/hook" ||
+		requests[1].values.Get("drop_pending_updates") != "true" {
+		t.Errorf("expected webhook to be set, got %v", requests)
+	}
+
+	resp, err := http.Post("http://"+runner.Addr().String()+"/hook", "application/json", strings.NewReader(testWebhookUpdate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	update := <-updates
+	if update.UpdateID != 1 {
+		t.Errorf("unexpected update: %d", update.UpdateID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := runner.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := <-updates; ok {
+		t.Error("expected updates channel to be closed")
+	}
+}
+
+func TestWebhookNeedsUpdate(t *testing.T) {
+	webhook, _ := NewWebhook("https://example.com/hook")
+	webhook.AllowedUpdates = []string{UpdateTypeMessage}
+
+	info := WebhookInfo{URL: "https://example.com/hook", AllowedUpdates: []string{UpdateTypeMessage}}
+	if webhookNeedsUpdate(info, webhook) {
+		t.Error("matching webhook should not need update")
+	}
+
+	info.AllowedUpdates = []string{UpdateTypeCallbackQuery}
+	if !webhookNeedsUpdate(info, webhook) {
+		t.Error("different allowed updates should need update")
+	}
+
+	info = WebhookInfo{URL: "https://example.com/other"}
+	if !webhookNeedsUpdate(info, webhook) {
+		t.Error("different URL should need update")
+	}
+
+	webhook.IPAddress = "1.2.3.4"
+	info = WebhookInfo{URL: "https://example.com/hook", AllowedUpdates: []string{UpdateTypeMessage}, IPAddress: "5.6.7.8"}
+	if !webhookNeedsUpdate(info, webhook) {
+		t.Error("different IP address should need update")
+	}
+}
+
+func TestRunnerWebhookListenError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	client := &testClient{results: map[string]string{
+		"getWebhookInfo": `{"url":"","pending_update_count":0}`,
+	}}
+
+	webhook, _ := NewWebhook("https://example.com/hook")
+	runner := NewRunner(newTestBot(client), NewWebhookRunnerConfig(webhook, listener.Addr().String()))
+
+	if _, err := runner.Start(); err == nil {
+		t.Fatal("expected error for address in use")
+	}
+
+	if requests := client.Requests(); len(requests) != 0 {
+		t.Errorf("expected webhook not to be set, got %v", requests)
+	}
+}
@@ -0,0 +1,233 @@
+package tgbotapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+)
+
+// DefaultCallbackSignatureLength is the number of HMAC bytes kept in signed
+// callback data by default. It is encoded as 11 base64 characters.
+const DefaultCallbackSignatureLength = 8
+
+// MinCallbackSignerKeyLength is the minimum length of the key of a
+// CallbackSigner.
+const MinCallbackSignerKeyLength = 16
+
+// Constant values for callback signature errors
+const (
+	// ErrCallbackSignature happens when callback data has a missing or
+	// invalid signature
+	ErrCallbackSignature = "callback data signature is invalid"
+	// ErrCallbackSignerKey happens when the key of a CallbackSigner is
+	// shorter than MinCallbackSignerKeyLength
+	ErrCallbackSignerKey = "callback signer key is too short"
+)
+
+// CallbackSigner signs callback data with a truncated HMAC bound to the
+// message carrying the keyboard, so clients can't forge button presses or
+// replay data from one message on another.
+//
+// The signature is appended to the callback data, so it reduces the space
+// available for data.
+type CallbackSigner struct {
+	// Key is the secret key, at least MinCallbackSignerKeyLength bytes long.
+	Key []byte
+	// SignatureLength is the number of HMAC bytes kept. Defaults to
+	// DefaultCallbackSignatureLength.
+	//
+	// optional
+	SignatureLength int
+	// Rejected is the answer to callback queries with an invalid signature.
+	// CallbackQueryID is filled in for every query.
+	Rejected CallbackConfig
+}
+
+// NewCallbackSigner creates a new CallbackSigner using a secret key, which
+// must be at least MinCallbackSignerKeyLength bytes long.
+func NewCallbackSigner(key []byte) (*CallbackSigner, error) {
+	if len(key) < MinCallbackSignerKeyLength {
+		return nil, errors.New(ErrCallbackSignerKey)
+	}
+
+	return &CallbackSigner{
+		Key:             key,
+		SignatureLength: DefaultCallbackSignatureLength,
+	}, nil
+}
+
+// signature returns the encoded signature of data for a message, identified
+// by chat and message ID or by inline message ID.
+func (s *CallbackSigner) signature(data string, chatID int64, messageID int, inlineMessageID string) (string, error) {
+	// Anyone could compute signatures with an empty or short key.
+	if len(s.Key) < MinCallbackSignerKeyLength {
+		return "", errors.New(ErrCallbackSignerKey)
+	}
+
+	length := s.SignatureLength
+	if length <= 0 || length > sha256.Size {
+		length = DefaultCallbackSignatureLength
+	}
+
+	mac := hmac.New(sha256.New, s.Key)
+	if inlineMessageID != "" {
+		mac.Write([]byte("i:" + inlineMessageID))
+	} else {
+		mac.Write([]byte(strconv.FormatInt(chatID, 10) + ":" + strconv.Itoa(messageID)))
+	}
+	mac.Write([]byte{0})
+	mac.Write([]byte(data))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:length]), nil
+}
+
+func (s *CallbackSigner) sign(data string, chatID int64, messageID int, inlineMessageID string) (string, error) {
+	signature, err := s.signature(data, chatID, messageID, inlineMessageID)
+	if err != nil {
+		return "", err
+	}
+
+	signed := data + signature
+	if len(signed) > MaxCallbackDataLength {
+		return "", errors.New(ErrCallbackDataTooLong)
+	}
+
+	return signed, nil
+}
+
+// Sign returns callback data with a signature for a message in a chat.
+func (s *CallbackSigner) Sign(data string, chatID int64, messageID int) (string, error) {
+	return s.sign(data, chatID, messageID, "")
+}
+
+// SignInline returns callback data with a signature for an inline message.
+func (s *CallbackSigner) SignInline(data string, inlineMessageID string) (string, error) {
+	return s.sign(data, 0, 0, inlineMessageID)
+}
+
+// SignMarkup returns a copy of markup with the callback data of every
+// button signed for a message in a chat.
+func (s *CallbackSigner) SignMarkup(markup InlineKeyboardMarkup, chatID int64, messageID int) (InlineKeyboardMarkup, error) {
+	signed := InlineKeyboardMarkup{
+		InlineKeyboard: make([][]InlineKeyboardButton, len(markup.InlineKeyboard)),
+	}
+
+	for i, row := range markup.InlineKeyboard {
+		signed.InlineKeyboard[i] = make([]InlineKeyboardButton, len(row))
+
+		for j, button := range row {
+			if button.CallbackData != nil {
+				data, err := s.Sign(*button.CallbackData, chatID, messageID)
+				if err != nil {
+					return InlineKeyboardMarkup{}, err
+				}
+
+				button.CallbackData = &data
+			}
+
+			signed.InlineKeyboard[i][j] = button
+		}
+	}
+
+	return signed, nil
+}
+
+// SendSigned sends a message with an inline keyboard, then signs its
+// callback data by editing the keyboard once the message ID is known.
+//
+// Buttons pressed before the keyboard is edited fail verification.
+func (s *CallbackSigner) SendSigned(bot *BotAPI, c Chattable) (Message, error) {
+	message, err := bot.Send(c)
+	if err != nil {
+		return message, err
+	}
+
+	if message.ReplyMarkup == nil || message.Chat == nil {
+		return message, nil
+	}
+
+	markup, err := s.SignMarkup(*message.ReplyMarkup, message.Chat.ID, message.MessageID)
+	if err != nil {
+		return message, err
+	}
+
+	if _, err := bot.Request(NewEditMessageReplyMarkup(message.Chat.ID, message.MessageID, markup)); err != nil {
+		return message, err
+	}
+
+	message.ReplyMarkup = &markup
+
+	return message, nil
+}
+
+// Verify checks the signature of the data of a callback query and returns
+// the data without it.
+func (s *CallbackSigner) Verify(query *CallbackQuery) (string, error) {
+	if query == nil {
+		return "", errors.New(ErrCallbackSignature)
+	}
+
+	empty, err := s.signature("", 0, 0, "")
+	if err != nil {
+		return "", err
+	}
+
+	length := len(empty)
+	if len(query.Data) < length {
+		return "", errors.New(ErrCallbackSignature)
+	}
+
+	data, signature := query.Data[:len(query.Data)-length], query.Data[len(query.Data)-length:]
+
+	var expected string
+	switch {
+	case query.InlineMessageID != "":
+		expected, err = s.signature(data, 0, 0, query.InlineMessageID)
+	case query.Message != nil && query.Message.Chat != nil:
+		expected, err = s.signature(data, query.Message.Chat.ID, query.Message.MessageID, "")
+	default:
+		return "", errors.New(ErrCallbackSignature)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", errors.New(ErrCallbackSignature)
+	}
+
+	return data, nil
+}
+
+// Middleware returns a Middleware which verifies the data of callback
+// queries before calling the next handler. Callback queries with an invalid
+// signature are answered with Rejected, and next receives the data without
+// the signature.
+func (s *CallbackSigner) Middleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+			if update.CallbackQuery == nil {
+				return next(ctx, bot, update)
+			}
+
+			data, err := s.Verify(update.CallbackQuery)
+			if err != nil {
+				log.Printf("Rejecting callback query %s: %s", update.CallbackQuery.ID, err)
+
+				rejected := s.Rejected
+				rejected.CallbackQueryID = update.CallbackQuery.ID
+				return rejected, nil
+			}
+
+			query := *update.CallbackQuery
+			query.Data = data
+			update.CallbackQuery = &query
+
+			return next(ctx, bot, update)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package tgbotapi
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func newTestCallbackSigner(t *testing.T, key string) *CallbackSigner {
+	t.Helper()
+
+	signer, err := NewCallbackSigner([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+func TestCallbackSigner(t *testing.T) {
+	signer := newTestCallbackSigner(t, "secret key of the test signer")
+
+	data, err := signer.Sign("approve:12345", 3, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := newTestCallbackUpdate(data)
+	if verified, err := signer.Verify(update.CallbackQuery); err != nil || verified != "approve:12345" {
+		t.Errorf("expected valid signature, got %q, %v", verified, err)
+	}
+
+	forged := newTestCallbackUpdate("approve:99999" + data[len("approve:12345"):])
+	if _, err := signer.Verify(forged.CallbackQuery); err == nil || err.Error() != ErrCallbackSignature {
+		t.Errorf("expected forged data to fail, got %v", err)
+	}
+
+	other := newTestCallbackUpdate(data)
+	other.CallbackQuery.Message.MessageID = 7
+	if _, err := signer.Verify(other.CallbackQuery); err == nil {
+		t.Error("expected data from another message to fail")
+	}
+
+	if _, err := newTestCallbackSigner(t, "other key of the test signer").Verify(update.CallbackQuery); err == nil {
+		t.Error("expected data signed with another key to fail")
+	}
+}
+
+func TestCallbackSignerMiddleware(t *testing.T) {
+	signer := newTestCallbackSigner(t, "secret key of the test signer")
+
+	var received []string
+
+	d := NewDispatcher()
+	d.Use(signer.Middleware())
+	d.HandleCallbackPrefix("approve:", func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		received = append(received, update.CallbackQuery.Data)
+		return nil, nil
+	})
+
+	bot := newTestBot(&testClient{})
+
+	data, _ := signer.Sign("approve:1", 3, 6)
+	d.Dispatch(context.Background(), bot, newTestCallbackUpdate(data))
+
+	c, _ := d.Dispatch(context.Background(), bot, newTestCallbackUpdate("approve:2"))
+
+	if len(received) != 1 || received[0] != "approve:1" {
+		t.Errorf("expected only verified data to be dispatched, got %v", received)
+	}
+
+	if rejected, ok := c.(CallbackConfig); !ok || rejected.CallbackQueryID != "5" {
+		t.Errorf("expected forged callback to be answered, got %#v", c)
+	}
+}
+
+func TestCallbackSignerSendSigned(t *testing.T) {
+	client := &testClient{results: map[string]string{
+		"sendMessage": `{"message_id":6,"date":0,"chat":{"id":3,"type":"private"},` +
+			`"reply_markup":{"inline_keyboard":[[{"text":"Approve","callback_data":"approve:1"}]]}}`,
+	}}
+
+	signer := newTestCallbackSigner(t, "secret key of the test signer")
+
+	msg := NewMessage(3, "Approve?")
+	msg.ReplyMarkup = NewInlineKeyboardMarkup(NewInlineKeyboardRow(NewInlineKeyboardButtonData("Approve", "approve:1")))
+
+	if _, err := signer.SendSigned(newTestBot(client), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 2 || requests[1].endpoint != "editMessageReplyMarkup" {
+		t.Fatalf("expected keyboard to be edited, got %v", requests)
+	}
+
+	var markup InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(requests[1].values.Get("reply_markup")), &markup); err != nil {
+		t.Fatal(err)
+	}
+
+	update := newTestCallbackUpdate(*markup.InlineKeyboard[0][0].CallbackData)
+	if data, err := signer.Verify(update.CallbackQuery); err != nil || data != "approve:1" {
+		t.Errorf("expected signed keyboard, got %q, %v", data, err)
+	}
+}
+
+func TestCallbackSignerShortKey(t *testing.T) {
+	if _, err := NewCallbackSigner([]byte("short")); err == nil || err.Error() != ErrCallbackSignerKey {
+		t.Errorf("expected short key to be rejected, got %v", err)
+	}
+
+	signer := &CallbackSigner{}
+
+	if _, err := signer.Sign("approve:1", 3, 6); err == nil || err.Error() != ErrCallbackSignerKey {
+		t.Errorf("expected signing without a key to fail, got %v", err)
+	}
+
+	update := newTestCallbackUpdate("approve:1AAAAAAAAAAA")
+	if _, err := signer.Verify(update.CallbackQuery); err == nil || err.Error() != ErrCallbackSignerKey {
+		t.Errorf("expected verifying without a key to fail, got %v", err)
+	}
+}
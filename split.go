@@ -0,0 +1,545 @@
+package tgbotapi
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Limits on the length of text in UTF-16 code units, after entities parsing
+const (
+	MaxMessageTextLength = 4096
+	MaxCaptionLength     = 1024
+)
+
+// TextChunk is a part of a text split by SplitText.
+type TextChunk struct {
+	Text string
+	// Entities are the entities in the chunk, with offsets relative to the
+	// start of the chunk.
+	//
+	// optional
+	Entities []MessageEntity
+}
+
+// SplitText splits text into chunks of at most limit UTF-16 code units after
+// entities parsing, such as MaxMessageTextLength or MaxCaptionLength.
+//
+// Text is split at paragraph, line, sentence or word boundaries where
+// possible. With a parse mode, formatting open at the end of a chunk is
+// closed and opened again at the start of the next one. Without one,
+// entities are split between chunks and their offsets adjusted.
+//
+// Text within the limit is returned unchanged as a single chunk.
+func SplitText(text, parseMode string, entities []MessageEntity, limit int) []TextChunk {
+	var tokens []markupToken
+
+	switch parseMode {
+	case ModeHTML:
+		tokens = tokenizeHTML(text)
+	case ModeMarkdown:
+		tokens = tokenizeMarkdown(text, false)
+	case ModeMarkdownV2:
+		tokens = tokenizeMarkdown(text, true)
+	default:
+		return splitEntities(text, entities, limit)
+	}
+
+	var visible []rune
+	for _, token := range tokens {
+		if token.visible {
+			visible = append(visible, token.r)
+		}
+	}
+
+	if utf16Length(visible) <= limit {
+		return []TextChunk{{Text: text, Entities: entities}}
+	}
+
+	var chunks []TextChunk
+	for _, chunk := range splitMarkup(tokens, splitRanges(visible, limit), parseMode == ModeMarkdownV2) {
+		chunks = append(chunks, TextChunk{Text: chunk})
+	}
+
+	return chunks
+}
+
+// splitEntities splits plain text, moving entities to the chunks they are
+// in.
+func splitEntities(text string, entities []MessageEntity, limit int) []TextChunk {
+	runes := []rune(text)
+
+	if utf16Length(runes) <= limit {
+		return []TextChunk{{Text: text, Entities: entities}}
+	}
+
+	// offsets maps rune indexes to UTF-16 offsets.
+	offsets := make([]int, len(runes)+1)
+	for i, r := range runes {
+		offsets[i+1] = offsets[i] + utf16.RuneLen(r)
+	}
+
+	var chunks []TextChunk
+	for _, r := range splitRanges(runes, limit) {
+		start, end := offsets[r.start], offsets[r.end]
+
+		chunk := TextChunk{Text: string(runes[r.start:r.end])}
+
+		for _, entity := range entities {
+			entityStart := entity.Offset
+			if entityStart < start {
+				entityStart = start
+			}
+
+			entityEnd := entity.Offset + entity.Length
+			if entityEnd > end {
+				entityEnd = end
+			}
+
+			if entityStart >= entityEnd {
+				continue
+			}
+
+			entity.Offset = entityStart - start
+			entity.Length = entityEnd - entityStart
+			chunk.Entities = append(chunk.Entities, entity)
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+func utf16Length(runes []rune) int {
+	length := 0
+	for _, r := range runes {
+		length += utf16.RuneLen(r)
+	}
+
+	return length
+}
+
+// textRange is a range of runes in a text.
+type textRange struct {
+	start, end int
+}
+
+// splitRanges returns the ranges of text each chunk contains, so that no
+// chunk is longer than limit UTF-16 code units. Whitespace between chunks is
+// dropped.
+func splitRanges(text []rune, limit int) []textRange {
+	var ranges []textRange
+
+	start := skipSpace(text, 0)
+	for start < len(text) {
+		end, length := start, 0
+		for end < len(text) {
+			n := utf16.RuneLen(text[end])
+			if length+n > limit {
+				break
+			}
+
+			length += n
+			end++
+		}
+
+		if end == start {
+			end++
+		}
+
+		if end < len(text) {
+			end = breakPoint(text, start, end)
+		}
+
+		trimmed := end
+		for trimmed > start && unicode.IsSpace(text[trimmed-1]) {
+			trimmed--
+		}
+
+		if trimmed > start {
+			ranges = append(ranges, textRange{start, trimmed})
+		}
+
+		start = skipSpace(text, end)
+	}
+
+	return ranges
+}
+
+func skipSpace(text []rune, i int) int {
+	for i < len(text) && unicode.IsSpace(text[i]) {
+		i++
+	}
+
+	return i
+}
+
+// Priorities of places to split text at
+const (
+	breakNone = iota
+	breakWord
+	breakSentence
+	breakLine
+	breakParagraph
+)
+
+// breakLevel returns how good a place splitting text before index i is.
+func breakLevel(text []rune, i int) int {
+	before := text[i-1]
+	after := ' '
+	if i < len(text) {
+		after = text[i]
+	}
+
+	switch {
+	case before == '\n' && i >= 2 && text[i-2] == '\n':
+		return breakParagraph
+	case before == '\n':
+		return breakLine
+	case unicode.IsSpace(after) && strings.ContainsRune(".!?…", before):
+		return breakSentence
+	case unicode.IsSpace(before) || unicode.IsSpace(after):
+		return breakWord
+	}
+
+	return breakNone
+}
+
+// breakPoint returns where to end a chunk starting at start which may not
+// extend past end.
+//
+// Paragraphs, lines and sentences are only preferred if they leave the chunk
+// at least half full. Words are split if there is no other choice.
+func breakPoint(text []rune, start, end int) int {
+	half := start + (end-start)/2
+
+	best, bestLevel := end, breakNone
+	for i := end; i > start; i-- {
+		level := breakLevel(text, i)
+		if level == breakWord && bestLevel == breakNone {
+			best, bestLevel = i, level
+		} else if level > bestLevel && i > half {
+			best, bestLevel = i, level
+		}
+	}
+
+	return best
+}
+
+// markupTag is formatting which can be opened and closed.
+type markupTag struct {
+	name  string
+	open  string
+	close string
+}
+
+// markupToken is a part of text formatted with a parse mode. It is either a
+// visible character or formatting opening or closing a tag.
+type markupToken struct {
+	raw     string
+	visible bool
+	r       rune
+	tag     *markupTag
+	closing bool
+}
+
+func visibleToken(raw string, r rune) markupToken {
+	return markupToken{raw: raw, visible: true, r: r}
+}
+
+// tokenizeHTML splits text formatted with ModeHTML into tokens.
+func tokenizeHTML(text string) []markupToken {
+	var tokens []markupToken
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '<':
+			if j := strings.IndexByte(text[i:], '>'); j != -1 {
+				raw := text[i : i+j+1]
+				inner := strings.TrimSpace(raw[1 : len(raw)-1])
+
+				if strings.HasPrefix(inner, "/") {
+					name := strings.ToLower(strings.TrimSpace(inner[1:]))
+					tokens = append(tokens, markupToken{raw: raw, tag: &markupTag{name: name}, closing: true})
+				} else {
+					name := inner
+					if fields := strings.Fields(inner); len(fields) > 0 {
+						name = fields[0]
+					}
+
+					name = strings.ToLower(name)
+					tokens = append(tokens, markupToken{raw: raw, tag: &markupTag{
+						name:  name,
+						open:  raw,
+						close: "</" + name + ">",
+					}})
+				}
+
+				i += j + 1
+				continue
+			}
+		case '&':
+			if j := strings.IndexByte(text[i:], ';'); j > 1 && j < 12 {
+				raw := text[i : i+j+1]
+				if decoded := html.UnescapeString(raw); decoded != raw {
+					r, _ := utf8.DecodeRuneInString(decoded)
+					tokens = append(tokens, visibleToken(raw, r))
+					i += j + 1
+					continue
+				}
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(text[i:])
+		tokens = append(tokens, visibleToken(text[i:i+size], r))
+		i += size
+	}
+
+	return tokens
+}
+
+// tokenizeMarkdown splits text formatted with ModeMarkdownV2, or ModeMarkdown
+// if v2 is false, into tokens.
+func tokenizeMarkdown(text string, v2 bool) []markupToken {
+	var (
+		tokens []markupToken
+		open   []string
+	)
+
+	markers := []string{"```", "`", "*", "_", "["}
+	if v2 {
+		markers = []string{"```", "`", "||", "__", "*", "_", "~", "![", "["}
+	}
+
+	isOpen := func(name string) bool {
+		for _, o := range open {
+			if o == name {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	pop := func(name string) {
+		for i := len(open) - 1; i >= 0; i-- {
+			if open[i] == name {
+				open = append(open[:i], open[i+1:]...)
+				return
+			}
+		}
+	}
+
+	code := func() string {
+		if len(open) > 0 && (open[len(open)-1] == "`" || open[len(open)-1] == "```") {
+			return open[len(open)-1]
+		}
+
+		return ""
+	}
+
+	lineStart := true
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		if rest[0] == '\\' && len(rest) > 1 {
+			r, size := utf8.DecodeRuneInString(rest[1:])
+			tokens = append(tokens, visibleToken(rest[:1+size], r))
+			i += 1 + size
+			lineStart = false
+			continue
+		}
+
+		if c := code(); c != "" {
+			if strings.HasPrefix(rest, c) {
+				tokens = append(tokens, markupToken{raw: c, tag: &markupTag{name: c}, closing: true})
+				pop(c)
+				i += len(c)
+				continue
+			}
+		} else {
+			// A quote marker is a tag lasting until the end of the line, so
+			// it is repeated when a quoted line is split.
+			if v2 && lineStart && rest[0] == '>' {
+				tokens = append(tokens, markupToken{raw: ">", tag: &markupTag{name: ">", open: ">"}})
+				open = append(open, ">")
+				i++
+				lineStart = false
+				continue
+			}
+
+			if rest[0] == ']' && isOpen("[") {
+				if j := strings.IndexByte(rest, ')'); strings.HasPrefix(rest, "](") && j != -1 {
+					tokens = append(tokens, markupToken{raw: rest[:j+1], tag: &markupTag{name: "["}, closing: true})
+					pop("[")
+					i += j + 1
+					continue
+				}
+			}
+
+			matched := false
+			for _, marker := range markers {
+				if !strings.HasPrefix(rest, marker) {
+					continue
+				}
+
+				name := marker
+				if name == "![" {
+					name = "["
+				}
+
+				if isOpen(name) && name != "[" {
+					tokens = append(tokens, markupToken{raw: marker, tag: &markupTag{name: name}, closing: true})
+					pop(name)
+					i += len(marker)
+				} else {
+					tag := &markupTag{name: name, open: marker, close: marker}
+
+					switch name {
+					case "[":
+						tag.close = markdownLinkClose(rest)
+					case "```":
+						// The language of a code block is part of the opening.
+						if j := strings.IndexByte(rest, '\n'); j != -1 {
+							tag.open = rest[:j+1]
+						}
+					}
+
+					tokens = append(tokens, markupToken{raw: tag.open, tag: tag})
+					open = append(open, name)
+					i += len(tag.open)
+				}
+
+				matched = true
+				break
+			}
+
+			if matched {
+				continue
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+
+		if r == '\n' && code() == "" && isOpen(">") {
+			tokens = append(tokens, markupToken{tag: &markupTag{name: ">"}, closing: true})
+			pop(">")
+		}
+
+		tokens = append(tokens, visibleToken(rest[:size], r))
+		i += size
+		lineStart = r == '\n'
+	}
+
+	return tokens
+}
+
+// markdownLinkClose returns the text closing the link starting at the start
+// of text, such as "](https://example.com)".
+func markdownLinkClose(text string) string {
+	for i := 1; i < len(text); i++ {
+		switch {
+		case text[i] == '\\':
+			i++
+		case strings.HasPrefix(text[i:], "]("):
+			if j := strings.IndexByte(text[i:], ')'); j != -1 {
+				return text[i : i+j+1]
+			}
+
+			return "]"
+		}
+	}
+
+	return "]"
+}
+
+// splitMarkup joins tokens into one string per range of visible characters,
+// closing and reopening formatting between chunks.
+func splitMarkup(tokens []markupToken, ranges []textRange, v2 bool) []string {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	chunks := make([]strings.Builder, len(ranges))
+
+	// MarkdownV2 treats __ as underline, so italic next to underline must
+	// be separated with a character Telegram ignores. Only formatting added
+	// between chunks can cause this, as the original text was unambiguous.
+	added := false
+	write := func(b *strings.Builder, s string, synthetic bool) {
+		if v2 && (synthetic || added) && strings.HasPrefix(s, "_") && strings.HasSuffix(b.String(), "_") {
+			b.WriteString("\r")
+		}
+
+		b.WriteString(s)
+		added = synthetic
+	}
+
+	var stack []*markupTag
+	current, index := 0, 0
+
+	advance := func() {
+		for current < len(ranges)-1 && index >= ranges[current].end {
+			for i := len(stack) - 1; i >= 0; i-- {
+				write(&chunks[current], stack[i].close, true)
+			}
+
+			current++
+
+			// A quote marker has to start the line, so it is reopened
+			// before any other formatting.
+			for _, tag := range stack {
+				if tag.name == ">" {
+					write(&chunks[current], tag.open, true)
+				}
+			}
+
+			for _, tag := range stack {
+				if tag.name != ">" {
+					write(&chunks[current], tag.open, true)
+				}
+			}
+		}
+	}
+
+	for _, token := range tokens {
+		switch {
+		case token.visible:
+			advance()
+
+			if index >= ranges[current].start && index < ranges[current].end {
+				write(&chunks[current], token.raw, false)
+			}
+
+			index++
+		case token.closing:
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == token.tag.name {
+					stack = append(stack[:i], stack[i+1:]...)
+					break
+				}
+			}
+
+			write(&chunks[current], token.raw, false)
+		default:
+			advance()
+
+			if token.tag != nil {
+				stack = append(stack, token.tag)
+			}
+
+			write(&chunks[current], token.raw, false)
+		}
+	}
+
+	result := make([]string, len(chunks))
+	for i := range chunks {
+		result[i] = chunks[i].String()
+	}
+
+	return result
+}
@@ -0,0 +1,183 @@
+package tgbotapi
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestSplitTextBoundaries(t *testing.T) {
+	text := "First paragraph here.\n\nSecond one. It has two sentences."
+
+	chunks := SplitText(text, "", nil, 40)
+	if len(chunks) != 2 || chunks[0].Text != "First paragraph here." || chunks[1].Text != "Second one. It has two sentences." {
+		t.Errorf("expected split at paragraph, got %q", chunks)
+	}
+
+	chunks = SplitText("Second one here. It has two sentences.", "", nil, 24)
+	if len(chunks) != 2 || chunks[0].Text != "Second one here." {
+		t.Errorf("expected split at sentence, got %q", chunks)
+	}
+
+	chunks = SplitText(strings.Repeat("word ", 10), "", nil, 12)
+	for _, chunk := range chunks {
+		if chunk.Text != "word word" {
+			t.Errorf("expected split at words, got %q", chunks)
+			break
+		}
+	}
+
+	chunks = SplitText(strings.Repeat("😀", 5), "", nil, 4)
+	if len(chunks) != 3 || chunks[0].Text != "😀😀" || chunks[2].Text != "😀" {
+		t.Errorf("expected UTF-16 aware split, got %q", chunks)
+	}
+
+	if chunks := SplitText(text, ModeHTML, nil, 100); len(chunks) != 1 || chunks[0].Text != text {
+		t.Errorf("expected short text to be unchanged, got %q", chunks)
+	}
+}
+
+func TestSplitTextEntities(t *testing.T) {
+	text := "😀 bold text here and more"
+	entities := []MessageEntity{{Type: "bold", Offset: 3, Length: 14}}
+
+	chunks := SplitText(text, "", entities, 12)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %q", chunks)
+	}
+
+	if chunks[0].Text != "😀 bold text" || len(chunks[2].Entities) != 0 {
+		t.Errorf("unexpected chunks %q", chunks)
+	}
+
+	expected := []string{"bold text", "here"}
+	for i, chunk := range chunks[:2] {
+		if len(chunk.Entities) != 1 {
+			t.Fatalf("expected entity in chunk %d, got %v", i, chunk.Entities)
+		}
+
+		entity := chunk.Entities[0]
+		runes := utf16.Encode([]rune(chunk.Text))
+		if got := string(utf16.Decode(runes[entity.Offset : entity.Offset+entity.Length])); got != expected[i] {
+			t.Errorf("expected entity %q in chunk %d, got %q", expected[i], i, got)
+		}
+	}
+}
+
+func TestSplitTextMarkup(t *testing.T) {
+	html := `<b>bold <a href="https://example.com">link text</a> end</b> &amp; more`
+
+	chunks := SplitText(html, ModeHTML, nil, 12)
+	expected := []string{
+		`<b>bold <a href="https://example.com">link</a></b>`,
+		`<b><a href="https://example.com">text</a> end</b> &amp;`,
+		`more`,
+	}
+
+	if len(chunks) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, chunks)
+	}
+
+	for i := range expected {
+		if chunks[i].Text != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], chunks[i].Text)
+		}
+	}
+
+	markdown := "*bold [link text](https://example.com) \\. end*"
+
+	chunks = SplitText(markdown, ModeMarkdownV2, nil, 12)
+	expected = []string{
+		"*bold [link](https://example.com)*",
+		"*[text](https://example.com) \\. end*",
+	}
+
+	if len(chunks) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, chunks)
+	}
+
+	for i := range expected {
+		if chunks[i].Text != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], chunks[i].Text)
+		}
+	}
+
+	chunks = SplitText("__underline _italic text_ end__", ModeMarkdownV2, nil, 20)
+	if len(chunks) != 2 || chunks[0].Text != "__underline _italic_\r__" {
+		t.Errorf("expected italic and underline to be separated, got %q", chunks)
+	}
+
+	chunks = SplitText("```go\nfunc a() {}\nfunc b() {}\n```", ModeMarkdownV2, nil, 14)
+	if len(chunks) != 2 || chunks[1].Text != "```go\nfunc b() {}```" {
+		t.Errorf("expected code block to be reopened, got %q", chunks)
+	}
+
+	quote := ">*" + strings.Repeat("quote text ", 30) + "*\n>more"
+
+	chunks = SplitText(quote, ModeMarkdownV2, nil, 100)
+	if len(chunks) != 4 {
+		t.Fatalf("expected quote to be split in 4 chunks, got %q", chunks)
+	}
+
+	for i, chunk := range chunks {
+		if !strings.HasPrefix(chunk.Text, ">*") {
+			t.Errorf("expected chunk %d to be quoted, got %q", i, chunk.Text)
+		}
+
+		if errs := ValidateFormatting(ModeMarkdownV2, chunk.Text); len(errs) != 0 {
+			t.Errorf("chunk %d is invalid: %q: %v", i, chunk.Text, errs)
+		}
+	}
+}
+
+func TestSendLong(t *testing.T) {
+	client := &testClient{results: map[string]string{
+		"sendMessage": `{"message_id":1,"date":0,"chat":{"id":3,"type":"private"}}`,
+	}}
+
+	msg := NewMessage(3, strings.Repeat("a", MaxMessageTextLength)+"\n\nb")
+	msg.ReplyToMessageID = 9
+	msg.ReplyMarkup = NewInlineKeyboardMarkup(NewInlineKeyboardRow(NewInlineKeyboardButtonData("a", "b")))
+
+	messages, err := newTestBot(client).SendLong(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := client.Requests()
+	if len(messages) != 2 || len(requests) != 2 {
+		t.Fatalf("expected 2 messages, got %v", requests)
+	}
+
+	if requests[0].values.Get("reply_markup") != "" || requests[1].values.Get("reply_markup") == "" {
+		t.Error("expected only the last message to have reply markup")
+	}
+
+	if requests[0].values.Get("reply_to_message_id") != "9" || requests[1].values.Get("reply_to_message_id") != "" {
+		t.Error("expected only the first message to be a reply")
+	}
+
+	if requests[1].values.Get("text") != "b" {
+		t.Errorf("expected second message to contain the rest, got %q", requests[1].values.Get("text"))
+	}
+}
+
+func TestSendLongEmpty(t *testing.T) {
+	client := &testClient{results: map[string]string{
+		"sendMessage": `{"message_id":1,"date":0,"chat":{"id":3,"type":"private"}}`,
+	}}
+
+	messages, err := newTestBot(client).SendLong(NewMessage(3, " \n "))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := client.Requests()
+	if len(messages) != 1 || len(requests) != 1 {
+		t.Fatalf("expected the message to be sent once, got %v", requests)
+	}
+
+	if requests[0].values.Get("text") != " \n " {
+		t.Errorf("expected text to be sent unchanged, got %q", requests[0].values.Get("text"))
+	}
+}
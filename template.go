@@ -0,0 +1,252 @@
+package tgbotapi
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// templateEscaper is the name of the function escaping the values of actions
+// in a Template.
+const templateEscaper = "_tgbotapi_escape"
+
+// Raw is text already formatted for the parse mode of a Template, which is
+// not escaped when interpolated.
+type Raw string
+
+// Template is a text/template producing text for a parse mode.
+//
+// Every value interpolated by an action is escaped for the parse mode, so
+// only formatting written in the template itself is kept. Values of type Raw
+// are interpolated as is.
+//
+// ModeMarkdown can't escape characters inside entities, so an entity is
+// closed and reopened around a value's character ending it. Values in links
+// and pre entities must not contain the characters ending them.
+type Template struct {
+	parseMode string
+	text      *template.Template
+	escaped   map[*parse.Tree]bool
+}
+
+// NewTemplate creates a new, empty Template producing text for parseMode,
+// which may be ModeHTML, ModeMarkdown or ModeMarkdownV2.
+func NewTemplate(name, parseMode string) *Template {
+	t := &Template{
+		parseMode: parseMode,
+		escaped:   make(map[*parse.Tree]bool),
+	}
+
+	t.text = template.New(name).Funcs(template.FuncMap{
+		templateEscaper: t.escape,
+	})
+
+	return t
+}
+
+// ParseMode returns the parse mode of the text produced by the template.
+func (t *Template) ParseMode() string {
+	return t.parseMode
+}
+
+// Funcs adds functions to the template, as text/template.Template.Funcs
+// does.
+func (t *Template) Funcs(funcs template.FuncMap) *Template {
+	t.text.Funcs(funcs)
+	return t
+}
+
+// Parse parses text as the template body, as text/template.Template.Parse
+// does.
+func (t *Template) Parse(text string) (*Template, error) {
+	if _, err := t.text.Parse(text); err != nil {
+		return nil, err
+	}
+
+	for _, tmpl := range t.text.Templates() {
+		if tmpl.Tree == nil || t.escaped[tmpl.Tree] {
+			continue
+		}
+
+		t.escapeNode(tmpl.Tree, tmpl.Tree.Root, "")
+		t.escaped[tmpl.Tree] = true
+	}
+
+	return t, nil
+}
+
+// Execute applies the template to data and writes the output to w.
+func (t *Template) Execute(w io.Writer, data interface{}) error {
+	return t.text.Execute(w, data)
+}
+
+// ExecuteTemplate applies the template associated with t with the given
+// name to data and writes the output to w.
+func (t *Template) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	return t.text.ExecuteTemplate(w, name, data)
+}
+
+// Render applies the template to data and returns the output.
+func (t *Template) Render(data interface{}) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// Message creates a new message with the template applied to data as text
+// and the parse mode of the template.
+func (t *Template) Message(chatID int64, data interface{}) (MessageConfig, error) {
+	text, err := t.Render(data)
+	if err != nil {
+		return MessageConfig{}, err
+	}
+
+	msg := NewMessage(chatID, text)
+	msg.ParseMode = t.parseMode
+
+	return msg, nil
+}
+
+// escape escapes a value interpolated by an action. For ModeMarkdown, entity
+// is the marker ending the entity the action is in, if any.
+func (t *Template) escape(entity string, value interface{}) (string, error) {
+	if raw, ok := value.(Raw); ok {
+		return string(raw), nil
+	}
+
+	text := fmt.Sprint(value)
+
+	switch t.parseMode {
+	case ModeHTML:
+		// Quotes are escaped too, so values are safe in attributes.
+		return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(text), nil
+	case ModeMarkdown:
+		return escapeMarkdownEntity(entity, text)
+	case ModeMarkdownV2:
+		return EscapeText(t.parseMode, text), nil
+	}
+
+	return "", fmt.Errorf("unsupported parse mode %q", t.parseMode)
+}
+
+// escapeMarkdownEntity escapes text for ModeMarkdown within the entity ending
+// with the given marker.
+//
+// Characters can't be escaped inside entities, so the entity is closed
+// before the character ending it and reopened after it.
+func escapeMarkdownEntity(entity, text string) (string, error) {
+	switch entity {
+	case "":
+		return EscapeText(ModeMarkdown, text), nil
+	case "*", "_", "`":
+		return strings.ReplaceAll(text, entity, entity+"\\"+entity+entity), nil
+	}
+
+	// Links and pre can't be reopened without repeating their URL or
+	// language.
+	end := entity
+	if end == "```" {
+		end = "`"
+	}
+
+	if strings.Contains(text, end) {
+		return "", fmt.Errorf("value %q can't be interpolated in an entity ending with %q", text, entity)
+	}
+
+	return text, nil
+}
+
+// markdownEntityAfter returns the marker ending the ModeMarkdown entity open
+// after text, given the one open before it, or an empty string if none is.
+func markdownEntityAfter(entity, text string) string {
+	for i := 0; i < len(text); i++ {
+		switch entity {
+		case "":
+			switch {
+			case text[i] == '\\' && i+1 < len(text) && strings.IndexByte("_*`[", text[i+1]) >= 0:
+				i++
+			case strings.HasPrefix(text[i:], "```"):
+				entity = "```"
+				i += 2
+			case text[i] == '_' || text[i] == '*' || text[i] == '`':
+				entity = text[i : i+1]
+			case text[i] == '[':
+				entity = "]"
+			}
+		case "]":
+			if text[i] != ']' {
+				continue
+			}
+
+			entity = ""
+			if strings.HasPrefix(text[i+1:], "(") {
+				entity = ")"
+				i++
+			}
+		default:
+			if strings.HasPrefix(text[i:], entity) {
+				i += len(entity) - 1
+				entity = ""
+			}
+		}
+	}
+
+	return entity
+}
+
+// escapeNode adds the escaper to the pipeline of every action printing a
+// value. It returns the ModeMarkdown entity open after node, given the one
+// open before it.
+func (t *Template) escapeNode(tree *parse.Tree, node parse.Node, entity string) string {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return entity
+		}
+
+		for _, n := range node.Nodes {
+			entity = t.escapeNode(tree, n, entity)
+		}
+	case *parse.TextNode:
+		if t.parseMode == ModeMarkdown {
+			entity = markdownEntityAfter(entity, string(node.Text))
+		}
+	case *parse.ActionNode:
+		// Actions declaring or assigning variables print nothing.
+		if len(node.Pipe.Decl) > 0 {
+			return entity
+		}
+
+		escaper := parse.NewIdentifier(templateEscaper).SetTree(tree).SetPos(node.Pos)
+
+		node.Pipe.Cmds = append(node.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      node.Pos,
+			Args: []parse.Node{escaper, &parse.StringNode{
+				NodeType: parse.NodeString,
+				Pos:      node.Pos,
+				Quoted:   strconv.Quote(entity),
+				Text:     entity,
+			}},
+		})
+	case *parse.IfNode:
+		// Branches are expected to leave the same entity open, so the one
+		// open after the main branch is used.
+		t.escapeNode(tree, node.ElseList, entity)
+		entity = t.escapeNode(tree, node.List, entity)
+	case *parse.RangeNode:
+		t.escapeNode(tree, node.ElseList, entity)
+		entity = t.escapeNode(tree, node.List, entity)
+	case *parse.WithNode:
+		t.escapeNode(tree, node.ElseList, entity)
+		entity = t.escapeNode(tree, node.List, entity)
+	}
+
+	return entity
+}
@@ -0,0 +1,128 @@
+package tgbotapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	type order struct {
+		Name  string
+		Items []string
+		Link  string
+		Note  Raw
+	}
+
+	data := order{
+		Name:  "<Bob> & *friends*",
+		Items: []string{"a_b", "1.5"},
+		Link:  `https://example.com/?q="x"`,
+		Note:  "<i>thanks</i>",
+	}
+
+	tests := []struct {
+		parseMode string
+		template  string
+		expected  string
+	}{
+		{
+			ModeHTML,
+			`<b>{{.Name}}</b>{{range .Items}} {{.}}{{end}} <a href="{{.Link}}">link</a> {{.Note}}`,
+			`<b>&lt;Bob&gt; &amp; *friends*</b> a_b 1.5 <a href="https://example.com/?q=&quot;x&quot;">link</a> <i>thanks</i>`,
+		},
+		{
+			ModeMarkdownV2,
+			`*{{.Name}}*{{range $i, $item := .Items}} {{$i}}: {{$item}}{{end}}{{if .Note}} done!{{end}}`,
+			`*<Bob\> & \*friends\**` + ` 0: a\_b 1: 1\.5 done!`,
+		},
+		{
+			ModeMarkdown,
+			`*{{.Name}}* {{printf "%s_%d" "x" 1}} _{{index .Items 0}}_ [{{index .Items 1}}]({{.Link}})`,
+			`*<Bob> & *\**friends*\*** x\_1 _a_\__b_ [1.5](https://example.com/?q="x")`,
+		},
+	}
+
+	for _, test := range tests {
+		tmpl, err := NewTemplate("order", test.parseMode).Parse(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		text, err := tmpl.Render(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if text != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.parseMode, test.expected, text)
+		}
+	}
+}
+
+func TestTemplateAssociated(t *testing.T) {
+	tmpl, err := NewTemplate("main", ModeHTML).
+		Funcs(map[string]interface{}{"upper": strings.ToUpper}).
+		Parse(`{{define "name"}}<b>{{upper .}}</b>{{end}}Hi {{template "name" .}}{{$x := "<"}}{{$x}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parsing again must not escape existing templates twice.
+	if _, err := tmpl.Parse(`{{define "other"}}{{.}}{{end}}` + `Hi {{template "name" .}}{{$x := "<"}}{{$x}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := tmpl.Message(3, "<x>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Text != "Hi <b>&lt;X&gt;</b>&lt;" || msg.ParseMode != ModeHTML || msg.ChatID != 3 {
+		t.Errorf("unexpected message %+v", msg)
+	}
+
+	bad, err := NewTemplate("bad", "").Parse("{{.}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bad.Render("x"); err == nil || !strings.Contains(err.Error(), "unsupported parse mode") {
+		t.Error("expected error for unsupported parse mode")
+	}
+}
+
+func TestTemplateMarkdownEntities(t *testing.T) {
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{"`{{.}}` \\*{{.}}", "`a`\\``*_b` \\*a\\`\\*\\_b"},
+		{"{{if .}}*{{.}}*{{end}} {{.}}", "*a`*\\**_b* a\\`\\*\\_b"},
+		{"```\n{{.}}```", ""},
+		{"[{{.}}](x)", "[a`*_b](x)"},
+	}
+
+	for _, test := range tests {
+		tmpl, err := NewTemplate("markdown", ModeMarkdown).Parse(test.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		text, err := tmpl.Render("a`*_b")
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%q: expected error, got %q", test.template, text)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if text != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.template, test.expected, text)
+		}
+	}
+}
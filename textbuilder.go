@@ -0,0 +1,174 @@
+package tgbotapi
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// TextBuilder builds formatted text as plain text and entities, so nothing
+// needs to be escaped for a parse mode.
+//
+// Entity offsets are calculated in UTF-16 code units as Telegram expects.
+// Methods return the builder, so calls can be chained.
+type TextBuilder struct {
+	text     strings.Builder
+	length   int
+	entities []builtEntity
+	// depth is the number of entities being wrapped.
+	depth int
+}
+
+// builtEntity is an entity with how deeply it is nested in other entities.
+type builtEntity struct {
+	entity MessageEntity
+	depth  int
+}
+
+// NewTextBuilder creates a new, empty TextBuilder.
+func NewTextBuilder() *TextBuilder {
+	return &TextBuilder{}
+}
+
+// Plain appends text without formatting.
+func (b *TextBuilder) Plain(text string) *TextBuilder {
+	b.text.WriteString(text)
+	b.length += len(utf16.Encode([]rune(text)))
+
+	return b
+}
+
+// Entity appends text with an entity. The offset and length of entity are
+// set by the builder.
+func (b *TextBuilder) Entity(text string, entity MessageEntity) *TextBuilder {
+	return b.Wrap(entity, func(b *TextBuilder) {
+		b.Plain(text)
+	})
+}
+
+// Wrap appends everything appended by build with an entity around it, so
+// entities can be nested. The offset and length of entity are set by the
+// builder.
+func (b *TextBuilder) Wrap(entity MessageEntity, build func(b *TextBuilder)) *TextBuilder {
+	start := b.length
+
+	b.depth++
+	build(b)
+	b.depth--
+
+	entity.Offset = start
+	entity.Length = b.length - start
+
+	if entity.Length > 0 {
+		b.entities = append(b.entities, builtEntity{entity, b.depth})
+	}
+
+	return b
+}
+
+// Bold appends bold text.
+func (b *TextBuilder) Bold(text string) *TextBuilder {
+	return b.Entity(text, MessageEntity{Type: "bold"})
+}
+
+// Italic appends italic text.
+func (b *TextBuilder) Italic(text string) *TextBuilder {
+	return b.Entity(text, MessageEntity{Type: "italic"})
+}
+
+// Underline appends underlined text.
+func (b *TextBuilder) Underline(text string) *TextBuilder {
+	return b.Entity(text, MessageEntity{Type: "underline"})
+}
+
+// Strikethrough appends strikethrough text.
+func (b *TextBuilder) Strikethrough(text string) *TextBuilder {
+	return b.Entity(text, MessageEntity{Type: "strikethrough"})
+}
+
+// Spoiler appends text hidden as a spoiler.
+func (b *TextBuilder) Spoiler(text string) *TextBuilder {
+	return b.Entity(text, MessageEntity{Type: "spoiler"})
+}
+
+// Code appends monowidth text.
+func (b *TextBuilder) Code(text string) *TextBuilder {
+	return b.Entity(text, MessageEntity{Type: "code"})
+}
+
+// Pre appends a monowidth block, with the programming language of the text
+// if language is not empty.
+func (b *TextBuilder) Pre(text, language string) *TextBuilder {
+	return b.Entity(text, MessageEntity{Type: "pre", Language: language})
+}
+
+// Link appends text linking to url.
+func (b *TextBuilder) Link(text, url string) *TextBuilder {
+	return b.Entity(text, MessageEntity{Type: "text_link", URL: url})
+}
+
+// Mention appends text mentioning a user, which works for users without a
+// username.
+func (b *TextBuilder) Mention(text string, user User) *TextBuilder {
+	return b.Entity(text, MessageEntity{Type: "text_mention", User: &user})
+}
+
+// CustomEmoji appends a custom emoji. emoji is shown where custom emoji are
+// not supported.
+func (b *TextBuilder) CustomEmoji(emoji, customEmojiID string) *TextBuilder {
+	return b.Entity(emoji, MessageEntity{Type: "custom_emoji", CustomEmojiID: customEmojiID})
+}
+
+// Len returns the length of the text in UTF-16 code units.
+func (b *TextBuilder) Len() int {
+	return b.length
+}
+
+// Text returns the text without formatting.
+func (b *TextBuilder) Text() string {
+	return b.text.String()
+}
+
+// Entities returns the entities of the text, ordered by offset with outer
+// entities first.
+func (b *TextBuilder) Entities() []MessageEntity {
+	built := append([]builtEntity(nil), b.entities...)
+
+	// Entities with the same range are ordered by how they were wrapped.
+	sort.SliceStable(built, func(i, j int) bool {
+		ei, ej := built[i].entity, built[j].entity
+		if ei.Offset != ej.Offset {
+			return ei.Offset < ej.Offset
+		}
+
+		if ei.Length != ej.Length {
+			return ei.Length > ej.Length
+		}
+
+		return built[i].depth < built[j].depth
+	})
+
+	var entities []MessageEntity
+	for _, e := range built {
+		entities = append(entities, e.entity)
+	}
+
+	return entities
+}
+
+// Message creates a new message with the text and entities.
+func (b *TextBuilder) Message(chatID int64) MessageConfig {
+	msg := NewMessage(chatID, b.Text())
+	msg.Entities = b.Entities()
+
+	return msg
+}
+
+// InputTextMessageContent creates the content of an inline query result with
+// the text and entities.
+func (b *TextBuilder) InputTextMessageContent() InputTextMessageContent {
+	return InputTextMessageContent{
+		Text:     b.Text(),
+		Entities: b.Entities(),
+	}
+}
@@ -0,0 +1,76 @@
+package tgbotapi
+
+import "testing"
+
+func TestTextBuilder(t *testing.T) {
+	b := NewTextBuilder().
+		Plain("😀 Hi ").
+		Bold("Bob").
+		Plain(", see ").
+		Link("docs", "https://example.com").
+		Plain(" ").
+		Wrap(MessageEntity{Type: "italic"}, func(b *TextBuilder) {
+			b.Plain("very ").Bold("важно")
+		}).
+		Plain("\n").
+		Pre("fmt.Println()", "go").
+		Mention("you", User{ID: 4}).
+		CustomEmoji("👍", "42").
+		Bold("")
+
+	if b.Text() != "😀 Hi Bob, see docs very важно\nfmt.Println()you👍" {
+		t.Errorf("unexpected text %q", b.Text())
+	}
+
+	msg := b.Message(3)
+	if msg.ParseMode != "" || msg.Text != b.Text() {
+		t.Errorf("unexpected message %+v", msg)
+	}
+
+	expected := []struct {
+		entityType string
+		text       string
+	}{
+		{"bold", "Bob"},
+		{"text_link", "docs"},
+		{"italic", "very важно"},
+		{"bold", "важно"},
+		{"pre", "fmt.Println()"},
+		{"text_mention", "you"},
+		{"custom_emoji", "👍"},
+	}
+
+	if len(msg.Entities) != len(expected) {
+		t.Fatalf("expected %d entities, got %v", len(expected), msg.Entities)
+	}
+
+	message := Message{Text: msg.Text, Entities: msg.Entities}
+	for i, e := range expected {
+		entity := msg.Entities[i]
+		if entity.Type != e.entityType || message.EntityText(entity) != e.text {
+			t.Errorf("expected %s entity %q, got %s %q", e.entityType, e.text, entity.Type, message.EntityText(entity))
+		}
+	}
+
+	if msg.Entities[1].URL != "https://example.com" || msg.Entities[4].Language != "go" ||
+		msg.Entities[5].User.ID != 4 || msg.Entities[6].CustomEmojiID != "42" {
+		t.Errorf("expected entity parameters to be kept, got %v", msg.Entities)
+	}
+
+	if content := b.InputTextMessageContent(); content.Text != b.Text() || len(content.Entities) != len(expected) {
+		t.Errorf("unexpected input message content %+v", content)
+	}
+}
+
+func TestTextBuilderNestedEntities(t *testing.T) {
+	b := NewTextBuilder().Wrap(MessageEntity{Type: "blockquote"}, func(b *TextBuilder) {
+		b.Wrap(MessageEntity{Type: "italic"}, func(b *TextBuilder) {
+			b.Bold("hello")
+		})
+	})
+
+	entities := b.Entities()
+	if len(entities) != 3 || entities[0].Type != "blockquote" || entities[1].Type != "italic" || entities[2].Type != "bold" {
+		t.Errorf("expected outer entities first, got %v", entities)
+	}
+}
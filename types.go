@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strings"
 	"time"
+	"unicode/utf16"
 )
 
 // APIResponse is a response from the Telegram API with the result
@@ -116,6 +117,43 @@ type Update struct {
 	ChatJoinRequest *ChatJoinRequest `json:"chat_join_request,omitempty"`
 }
 
+// Type returns the type of the update, such as UpdateTypeMessage, or an
+// empty string if it is of an unknown type.
+func (u *Update) Type() string {
+	switch {
+	case u.Message != nil:
+		return UpdateTypeMessage
+	case u.EditedMessage != nil:
+		return UpdateTypeEditedMessage
+	case u.ChannelPost != nil:
+		return UpdateTypeChannelPost
+	case u.EditedChannelPost != nil:
+		return UpdateTypeEditedChannelPost
+	case u.InlineQuery != nil:
+		return UpdateTypeInlineQuery
+	case u.ChosenInlineResult != nil:
+		return UpdateTypeChosenInlineResult
+	case u.CallbackQuery != nil:
+		return UpdateTypeCallbackQuery
+	case u.ShippingQuery != nil:
+		return UpdateTypeShippingQuery
+	case u.PreCheckoutQuery != nil:
+		return UpdateTypePreCheckoutQuery
+	case u.Poll != nil:
+		return UpdateTypePoll
+	case u.PollAnswer != nil:
+		return UpdateTypePollAnswer
+	case u.MyChatMember != nil:
+		return UpdateTypeMyChatMember
+	case u.ChatMember != nil:
+		return UpdateTypeChatMember
+	case u.ChatJoinRequest != nil:
+		return UpdateTypeChatJoinRequest
+	default:
+		return ""
+	}
+}
+
 // SentFrom returns the user who sent an update. Can be nil, if Telegram did not provide information
 // about the user in the update object.
 func (u *Update) SentFrom() *User {
@@ -158,7 +196,7 @@ func (u *Update) FromChat() *Chat {
 		return u.ChannelPost.Chat
 	case u.EditedChannelPost != nil:
 		return u.EditedChannelPost.Chat
-	case u.CallbackQuery != nil:
+	case u.CallbackQuery != nil && u.CallbackQuery.Message != nil:
 		return u.CallbackQuery.Message.Chat
 	default:
 		return nil
@@ -677,8 +715,7 @@ func (m *Message) CommandWithAt() string {
 	}
 
 	// IsCommand() checks that the message begins with a bot_command entity
-	entity := m.Entities[0]
-	return m.Text[1:entity.Length]
+	return strings.TrimPrefix(m.EntityText(m.Entities[0]), "/")
 }
 
 // CommandArguments checks if the message was a command and if it was,
@@ -698,11 +735,105 @@ func (m *Message) CommandArguments() string {
 	// IsCommand() checks that the message begins with a bot_command entity
 	entity := m.Entities[0]
 
-	if len(m.Text) == entity.Length {
+	text := utf16.Encode([]rune(m.Text))
+	if len(text) <= entity.Length+1 {
 		return "" // The command makes up the whole message
 	}
 
-	return m.Text[entity.Length+1:]
+	return string(utf16.Decode(text[entity.Length+1:]))
+}
+
+// textEntities returns the text of the message and its entities, or the
+// caption and its entities if the message has no text.
+func (m *Message) textEntities() (string, []MessageEntity) {
+	if m.Text == "" && len(m.Entities) == 0 {
+		return m.Caption, m.CaptionEntities
+	}
+
+	return m.Text, m.Entities
+}
+
+// EntityText returns the part of the text, or the caption if the message
+// has no text, that an entity refers to.
+//
+// Entity offsets are in UTF-16 code units, so slicing the text directly
+// gives the wrong result when it contains characters such as emoji.
+func (m *Message) EntityText(e MessageEntity) string {
+	text, _ := m.textEntities()
+	return entityText(text, e)
+}
+
+// entityTexts returns the text of every entity of a type.
+func (m *Message) entityTexts(entityType string) []string {
+	text, entities := m.textEntities()
+
+	var texts []string
+	for _, e := range entities {
+		if e.Type == entityType {
+			texts = append(texts, entityText(text, e))
+		}
+	}
+
+	return texts
+}
+
+// URLs returns the URLs in the text or caption of the message, as written.
+// Use TextLinks for links with different text.
+func (m *Message) URLs() []string {
+	return m.entityTexts("url")
+}
+
+// Mentions returns the @username mentions in the text or caption of the
+// message.
+func (m *Message) Mentions() []string {
+	return m.entityTexts("mention")
+}
+
+// Hashtags returns the #hashtags in the text or caption of the message.
+func (m *Message) Hashtags() []string {
+	return m.entityTexts("hashtag")
+}
+
+// TextLinks returns the text_link entities in the text or caption of the
+// message. Use EntityText to get the text of a link.
+func (m *Message) TextLinks() []MessageEntity {
+	_, entities := m.textEntities()
+
+	var links []MessageEntity
+	for _, e := range entities {
+		if e.IsTextLink() {
+			links = append(links, e)
+		}
+	}
+
+	return links
+}
+
+// CustomEmoji returns the IDs of the custom emoji in the text or caption of
+// the message.
+func (m *Message) CustomEmoji() []string {
+	_, entities := m.textEntities()
+
+	var ids []string
+	for _, e := range entities {
+		if e.IsCustomEmoji() {
+			ids = append(ids, e.CustomEmojiID)
+		}
+	}
+
+	return ids
+}
+
+// entityText returns the part of text an entity refers to, or an empty
+// string if the entity is outside of the text.
+func entityText(text string, e MessageEntity) string {
+	encoded := utf16.Encode([]rune(text))
+
+	if e.Offset < 0 || e.Length < 0 || e.Offset+e.Length > len(encoded) {
+		return ""
+	}
+
+	return string(utf16.Decode(encoded[e.Offset : e.Offset+e.Length]))
 }
 
 // MessageID represents a unique message identifier.
@@ -729,7 +860,8 @@ type MessageEntity struct {
 	//  “code” (monowidth string),
 	//  “pre” (monowidth block),
 	//  “text_link” (for clickable text URLs),
-	//  “text_mention” (for users without usernames)
+	//  “text_mention” (for users without usernames),
+	//  “custom_emoji” (for inline custom emoji stickers)
 	Type string `json:"type"`
 	// Offset in UTF-16 code units to the start of the entity
 	Offset int `json:"offset"`
@@ -747,6 +879,10 @@ type MessageEntity struct {
 	//
 	// optional
 	Language string `json:"language,omitempty"`
+	// CustomEmojiID for “custom_emoji” only, unique identifier of the custom emoji
+	//
+	// optional
+	CustomEmojiID string `json:"custom_emoji_id,omitempty"`
 }
 
 // ParseURL attempts to parse a URL contained within a MessageEntity.
@@ -814,6 +950,11 @@ func (e MessageEntity) IsTextLink() bool {
 	return e.Type == "text_link"
 }
 
+// IsCustomEmoji returns true if the type of the message entity is "custom_emoji".
+func (e MessageEntity) IsCustomEmoji() bool {
+	return e.Type == "custom_emoji"
+}
+
 // PhotoSize represents one size of a photo or a file / sticker thumbnail.
 type PhotoSize struct {
 	// FileID identifier for this file, which can be used to download or reuse
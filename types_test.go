@@ -379,3 +379,78 @@ var (
 	_ RequestFileData = (*FileID)(nil)
 	_ RequestFileData = (*fileAttach)(nil)
 )
+
+func TestUpdateType(t *testing.T) {
+	tests := []struct {
+		update   Update
+		expected string
+	}{
+		{Update{Message: &Message{}}, UpdateTypeMessage},
+		{Update{EditedChannelPost: &Message{}}, UpdateTypeEditedChannelPost},
+		{Update{CallbackQuery: &CallbackQuery{}}, UpdateTypeCallbackQuery},
+		{Update{ChatMember: &ChatMemberUpdated{}}, UpdateTypeChatMember},
+		{Update{ChatJoinRequest: &ChatJoinRequest{}}, UpdateTypeChatJoinRequest},
+		{Update{}, ""},
+	}
+
+	for _, test := range tests {
+		if actual := test.update.Type(); actual != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestMessageCommandWithUTF16(t *testing.T) {
+	message := Message{Text: "/привет😀@testbot аргументы 😀"}
+	message.Entities = []MessageEntity{{Type: "bot_command", Offset: 0, Length: 17}}
+
+	if message.CommandWithAt() != "привет😀@testbot" {
+		t.Errorf("unexpected command %q", message.CommandWithAt())
+	}
+
+	if message.Command() != "привет😀" {
+		t.Errorf("unexpected command %q", message.Command())
+	}
+
+	if message.CommandArguments() != "аргументы 😀" {
+		t.Errorf("unexpected arguments %q", message.CommandArguments())
+	}
+}
+
+func TestMessageEntityHelpers(t *testing.T) {
+	message := Message{
+		Caption: "😀 @user #tag https://example.com link 👍",
+		CaptionEntities: []MessageEntity{
+			{Type: "mention", Offset: 3, Length: 5},
+			{Type: "hashtag", Offset: 9, Length: 4},
+			{Type: "url", Offset: 14, Length: 19},
+			{Type: "text_link", Offset: 34, Length: 4, URL: "https://example.org"},
+			{Type: "custom_emoji", Offset: 39, Length: 2, CustomEmojiID: "42"},
+		},
+	}
+
+	if mentions := message.Mentions(); len(mentions) != 1 || mentions[0] != "@user" {
+		t.Errorf("unexpected mentions %q", mentions)
+	}
+
+	if hashtags := message.Hashtags(); len(hashtags) != 1 || hashtags[0] != "#tag" {
+		t.Errorf("unexpected hashtags %q", hashtags)
+	}
+
+	if urls := message.URLs(); len(urls) != 1 || urls[0] != "https://example.com" {
+		t.Errorf("unexpected urls %q", urls)
+	}
+
+	links := message.TextLinks()
+	if len(links) != 1 || message.EntityText(links[0]) != "link" || links[0].URL != "https://example.org" {
+		t.Errorf("unexpected text links %v", links)
+	}
+
+	if emoji := message.CustomEmoji(); len(emoji) != 1 || emoji[0] != "42" {
+		t.Errorf("unexpected custom emoji %q", emoji)
+	}
+
+	if text := message.EntityText(MessageEntity{Offset: 40, Length: 5}); text != "" {
+		t.Errorf("expected entity outside of text to be empty, got %q", text)
+	}
+}
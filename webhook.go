@@ -0,0 +1,110 @@
+package tgbotapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultWebhookTimeout is how long a WebhookHandler waits for its handler
+// before answering the webhook request without a response.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// WebhookHandler is a http.Handler which runs a HandlerFunc synchronously
+// for each update received through a webhook.
+//
+// The Chattable returned by the handler is written as the webhook response,
+// including any files which need to be uploaded. If the handler takes longer
+// than Timeout, the webhook request is answered with an empty response and
+// the Chattable is sent using Request once the handler finishes.
+type WebhookHandler struct {
+	Bot     *BotAPI
+	Handler HandlerFunc
+	// Timeout is how long to wait for Handler before falling back to a
+	// regular request. If zero, DefaultWebhookTimeout is used.
+	Timeout time.Duration
+	// Allowlist rejects requests not coming from its networks.
+	//
+	// optional
+	Allowlist *IPAllowlist
+}
+
+// NewWebhookHandler creates a new WebhookHandler.
+//
+// bot is used to parse updates and send delayed responses, handler is called
+// for every update.
+func NewWebhookHandler(bot *BotAPI, handler HandlerFunc) *WebhookHandler {
+	return &WebhookHandler{
+		Bot:     bot,
+		Handler: handler,
+		Timeout: DefaultWebhookTimeout,
+	}
+}
+
+type handlerResult struct {
+	chattable Chattable
+	err       error
+}
+
+// ServeHTTP handles a single webhook request.
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Allowlist != nil && !h.Allowlist.IsAllowed(r) {
+		writeForbidden(w)
+		return
+	}
+
+	update, err := h.Bot.HandleUpdate(r)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	// The request context is canceled once we respond, but the handler may
+	// outlive the request when it exceeds the timeout.
+	done := make(chan handlerResult, 1)
+	go func() {
+		// net/http only recovers panics in the goroutine serving the
+		// request, so a panicking handler would crash the server.
+		c, err := recoverHandler(h.Handler)(context.Background(), h.Bot, *update)
+		done <- handlerResult{c, err}
+	}()
+
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultWebhookTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		if result.err != nil {
+			logHandlerError(fmt.Sprintf("update %d", update.UpdateID), result.err)
+			return
+		}
+
+		if result.chattable == nil {
+			return
+		}
+
+		if err := writeToHTTPResponse(w, result.chattable, h.Bot.CheckFormatting); err != nil {
+			log.Printf("Failed to write response to update %d: %s", update.UpdateID, err)
+		}
+	case <-timer.C:
+		if h.Bot.Debug {
+			log.Printf("Handler for update %d exceeded %s, responding later", update.UpdateID, timeout)
+		}
+
+		go func() {
+			result := <-done
+			if result.err != nil {
+				logHandlerError(fmt.Sprintf("update %d", update.UpdateID), result.err)
+				return
+			}
+
+			sendHandlerResult(h.Bot, *update, result.chattable)
+		}()
+	}
+}
@@ -0,0 +1,247 @@
+package tgbotapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testRequest struct {
+	endpoint string
+	values   url.Values
+}
+
+// testClient is a HTTPClient which records requests instead of sending them
+// to Telegram. Results holds the raw JSON result to return per endpoint,
+// every other endpoint returns true.
+type testClient struct {
+	mu       sync.Mutex
+	requests []testRequest
+	results  map[string]string
+}
+
+func (c *testClient) Do(req *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			return nil, err
+		}
+	} else if err := req.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	endpoint := path.Base(req.URL.Path)
+
+	c.mu.Lock()
+	c.requests = append(c.requests, testRequest{endpoint, req.Form})
+	result, ok := c.results[endpoint]
+	c.mu.Unlock()
+
+	if !ok {
+		result = "true"
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true,"result":` + result + `}`)),
+	}, nil
+}
+
+func (c *testClient) Requests() []testRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]testRequest(nil), c.requests...)
+}
+
+func newTestBot(client HTTPClient) *BotAPI {
+	return &BotAPI{
+		Token:           "test",
+		Buffer:          100,
+		Self:            User{ID: 1, IsBot: true, FirstName: "Test", UserName: "test_bot"},
+		Client:          client,
+		shutdownChannel: make(chan interface{}),
+		apiEndpoint:     APIEndpoint,
+	}
+}
+
+func newWebhookRequest(body string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+}
+
+const testWebhookUpdate = `{"update_id":1,"message":{"message_id":2,"date":0,"chat":{"id":3,"type":"private"},"text":"hi"}}`
+
+func TestWebhookHandlerResponse(t *testing.T) {
+	bot := newTestBot(&testClient{})
+
+	h := NewWebhookHandler(bot, func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		return NewMessage(update.Message.Chat.ID, update.Message.Text), nil
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newWebhookRequest(testWebhookUpdate))
+
+	values, err := url.ParseQuery(w.Body.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values.Get("method") != "sendMessage" ||
+		values.Get("chat_id") != "3" ||
+		values.Get("text") != "hi" {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
+
+func TestWebhookHandlerMultipartResponse(t *testing.T) {
+	bot := newTestBot(&testClient{})
+
+	h := NewWebhookHandler(bot, func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		return NewDocument(update.Message.Chat.ID, FileBytes{Name: "test.txt", Bytes: []byte("test")}), nil
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newWebhookRequest(testWebhookUpdate))
+
+	r := httptest.NewRequest(http.MethodPost, "/", w.Body)
+	r.Header.Set("Content-Type", w.Header().Get("Content-Type"))
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.FormValue("method") != "sendDocument" || r.FormValue("chat_id") != "3" {
+		t.Errorf("unexpected response fields: %v", r.MultipartForm.Value)
+	}
+
+	files := r.MultipartForm.File["document"]
+	if len(files) != 1 || files[0].Filename != "test.txt" {
+		t.Errorf("expected uploaded document, got %v", r.MultipartForm.File)
+	}
+}
+
+func TestWebhookHandlerTimeout(t *testing.T) {
+	client := &testClient{}
+	bot := newTestBot(client)
+
+	release := make(chan struct{})
+	sent := make(chan struct{})
+
+	h := NewWebhookHandler(bot, func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		<-release
+		defer close(sent)
+		return NewMessage(update.Message.Chat.ID, "late"), nil
+	})
+	h.Timeout = time.Millisecond
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newWebhookRequest(testWebhookUpdate))
+
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected empty response, got %d: %s", w.Code, w.Body.String())
+	}
+
+	close(release)
+	<-sent
+
+	deadline := time.Now().Add(time.Second)
+	for len(client.Requests()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 1 ||
+		requests[0].endpoint != "sendMessage" ||
+		requests[0].values.Get("text") != "late" {
+		t.Errorf("expected delayed sendMessage, got %v", requests)
+	}
+}
+
+func TestWebhookHandlerBadRequest(t *testing.T) {
+	bot := newTestBot(&testClient{})
+
+	h := NewWebhookHandler(bot, func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		t.Error("handler should not be called")
+		return nil, nil
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newWebhookRequest("{"))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected bad request, got %d", w.Code)
+	}
+}
+
+// recordingLogger is a BotLogger recording the lines logged.
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Println(v ...interface{}) {
+	l.Printf("%s", fmt.Sprintln(v...))
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string(nil), l.lines...)
+}
+
+func TestWebhookHandlerPanic(t *testing.T) {
+	logger := &recordingLogger{}
+	previous := log
+	SetLogger(logger)
+	defer SetLogger(previous)
+
+	bot := newTestBot(&testClient{})
+
+	h := NewWebhookHandler(bot, func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newWebhookRequest(testWebhookUpdate))
+
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected empty response, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if lines := logger.Lines(); len(lines) != 1 || !strings.HasPrefix(lines[0], "Handler panicked on update 1: boom") {
+		t.Errorf("expected panic to be logged once, got %q", lines)
+	}
+}
+
+func TestWebhookHandlerCheckFormatting(t *testing.T) {
+	bot := newTestBot(&testClient{})
+	bot.CheckFormatting = true
+
+	h := NewWebhookHandler(bot, func(ctx context.Context, bot *BotAPI, update Update) (Chattable, error) {
+		msg := NewMessage(update.Message.Chat.ID, "<b>unclosed")
+		msg.ParseMode = ModeHTML
+		return msg, nil
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newWebhookRequest(testWebhookUpdate))
+
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected invalid response not to be written, got %d: %s", w.Code, w.Body.String())
+	}
+}